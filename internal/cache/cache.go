@@ -0,0 +1,101 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cache implements on-disk cache of JSON values.
+package cache
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Cache stores values as JSON files in the directory
+type Cache struct {
+	Dir string
+}
+
+// DefaultDir returns taskgram directory in the user cache directory,
+// e.g. $XDG_CACHE_HOME/taskgram on Linux.
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "taskgram"), nil
+}
+
+// New returns cache in taskgram subdirectory of the directory,
+// so Clear doesn't remove other files. DefaultDir is used if dir is empty.
+func New(dir string) (*Cache, error) {
+	if len(dir) < 1 {
+		var err error
+		dir, err = DefaultDir()
+		if err != nil {
+			return nil, err
+		}
+		return &Cache{Dir: dir}, nil
+	}
+	return &Cache{Dir: filepath.Join(dir, "taskgram")}, nil
+}
+
+// Get reads the value by key to v.
+// Returns false if there is no value or it can't be decoded.
+func (c *Cache) Get(key string, v interface{}) bool {
+	b, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(b, v) == nil
+}
+
+// Put writes the value by key.
+// The file is replaced atomically, so concurrent readers see either old or new value.
+func (c *Cache) Put(key string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Clear removes all cached values.
+func (c *Cache) Clear() error {
+	return os.RemoveAll(c.Dir)
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.Dir, filepath.FromSlash(key)+".json")
+}
@@ -0,0 +1,84 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPutGet(t *testing.T) {
+	c, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v map[string]int
+	if c.Get("notion/block-1", &v) {
+		t.Error("Get() found a value in the empty cache")
+	}
+
+	want := map[string]int{"a": 1}
+	if err := c.Put("notion/block-1", want); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Get("notion/block-1", &v) || !reflect.DeepEqual(v, want) {
+		t.Errorf("Get() = %v, want %v", v, want)
+	}
+
+	// Values which can't be decoded are misses
+	var s string
+	if c.Get("notion/block-1", &s) {
+		t.Error("Get() decoded a map to a string")
+	}
+}
+
+func TestClearKeepsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	other := filepath.Join(dir, "other", "data.json")
+	if err := os.MkdirAll(filepath.Dir(other), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(other, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Dir != filepath.Join(dir, "taskgram") {
+		t.Errorf("Dir = %q, want taskgram subdirectory of %q", c.Dir, dir)
+	}
+	if err := c.Put("notion/block-1", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Clear(); err != nil {
+		t.Fatal(err)
+	}
+
+	var v int
+	if c.Get("notion/block-1", &v) {
+		t.Error("Get() found a value after Clear")
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("file outside the cache is removed: %v", err)
+	}
+}
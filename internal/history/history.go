@@ -0,0 +1,357 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package history stores generated reports in an embedded database.
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/nemca/taskgram/pkg/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+// keyLayout is the layout of report keys, sortable by time
+const keyLayout = "2006-01-02T15:04:05.000000000Z"
+
+var (
+	reportsBucket = []byte("reports")
+	// wordsBucket has a nested bucket of report keys for every word of reports
+	wordsBucket = []byte("words")
+
+	ErrNotFound = errors.New("not found")
+)
+
+// Entry is a report generated at some time
+type Entry struct {
+	CreatedAt time.Time     `json:"created_at"`
+	Report    models.Report `json:"report"`
+}
+
+// Match is a task or event of the report matched by search query
+type Match struct {
+	CreatedAt time.Time
+	Task      *models.Task
+	// Notes are notes of the task with any word of the query,
+	// or all notes if only the title matches.
+	Notes []string
+	Event *models.Event
+}
+
+// Store keeps reports in bbolt database
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens the database file, creating it if needed.
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open history %s: %v", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(reportsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(wordsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Add records the report and indexes its words for search.
+// The report replaces reports generated earlier the same day in the local time zone,
+// so repeated runs keep one report per day.
+func (s *Store) Add(report *models.Report) error {
+	return s.add(report, time.Now())
+}
+
+func (s *Store) add(report *models.Report, createdAt time.Time) error {
+	entry := Entry{CreatedAt: createdAt, Report: *report}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	key := []byte(entry.CreatedAt.UTC().Format(keyLayout))
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		y, m, d := entry.CreatedAt.Local().Date()
+		dayStart := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+		if err := deleteReports(tx, []byte(dayStart.UTC().Format(keyLayout)), key); err != nil {
+			return err
+		}
+
+		if err := tx.Bucket(reportsBucket).Put(key, b); err != nil {
+			return err
+		}
+		words := tx.Bucket(wordsBucket)
+		for _, word := range reportWords(report) {
+			keys, err := words.CreateBucketIfNotExists([]byte(word))
+			if err != nil {
+				return err
+			}
+			if err := keys.Put(key, []byte{1}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// deleteReports deletes reports with keys from start to end exclusive and their words
+func deleteReports(tx *bolt.Tx, start, end []byte) error {
+	reports := tx.Bucket(reportsBucket)
+	words := tx.Bucket(wordsBucket)
+
+	var keys [][]byte
+	c := reports.Cursor()
+	for k, v := c.Seek(start); k != nil && bytes.Compare(k, end) < 0; k, v = c.Next() {
+		var entry Entry
+		if err := json.Unmarshal(v, &entry); err != nil {
+			return fmt.Errorf("decode report %s: %v", k, err)
+		}
+		for _, word := range reportWords(&entry.Report) {
+			keys := words.Bucket([]byte(word))
+			if keys == nil {
+				continue
+			}
+			if err := keys.Delete(k); err != nil {
+				return err
+			}
+			if first, _ := keys.Cursor().First(); first == nil {
+				if err := words.DeleteBucket([]byte(word)); err != nil {
+					return err
+				}
+			}
+		}
+		keys = append(keys, append([]byte(nil), k...))
+	}
+	for _, k := range keys {
+		if err := reports.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List returns all entries, oldest first.
+func (s *Store) List() ([]Entry, error) {
+	var entries []Entry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(reportsBucket).ForEach(func(k, v []byte) error {
+			var entry Entry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return fmt.Errorf("decode report %s: %v", k, err)
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	return entries, err
+}
+
+// Day returns the last report generated on the date in the local time zone.
+// Returns ErrNotFound if there are no reports for the date.
+func (s *Store) Day(date time.Time) (*Entry, error) {
+	entries, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	y, m, d := date.Date()
+	for i := len(entries) - 1; i >= 0; i-- {
+		ey, em, ed := entries[i].CreatedAt.Local().Date()
+		if ey == y && em == m && ed == d {
+			return &entries[i], nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Search returns done tasks and events which contain all words of the query, oldest first.
+// A task with the same notes or an event reported several times is returned once.
+func (s *Store) Search(query string) ([]Match, error) {
+	queryWords := words(query)
+	if len(queryWords) < 1 {
+		return nil, fmt.Errorf("empty search query")
+	}
+
+	var matches []Match
+	seen := make(map[string]bool)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		keys := searchKeys(tx.Bucket(wordsBucket), queryWords)
+		reports := tx.Bucket(reportsBucket)
+		for _, key := range keys {
+			var entry Entry
+			if err := json.Unmarshal(reports.Get([]byte(key)), &entry); err != nil {
+				return fmt.Errorf("decode report %s: %v", key, err)
+			}
+			for _, m := range entryMatches(&entry, queryWords) {
+				if id := m.id(); !seen[id] {
+					seen[id] = true
+					matches = append(matches, m)
+				}
+			}
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// id identifies the same task notes or event in different reports
+func (m *Match) id() string {
+	if m.Event != nil {
+		return "event\x00" + m.Event.Summary + "\x00" + m.Event.Start.UTC().Format(time.RFC3339)
+	}
+	title := m.Task.URL
+	if len(title) < 1 {
+		title = m.Task.Title
+	}
+	return "task\x00" + title + "\x00" + strings.Join(m.Notes, "\x00")
+}
+
+// searchKeys returns sorted keys of reports which have all words
+func searchKeys(index *bolt.Bucket, queryWords []string) []string {
+	var result []string
+	for i, word := range queryWords {
+		keys := index.Bucket([]byte(word))
+		if keys == nil {
+			return nil
+		}
+		if i == 0 {
+			_ = keys.ForEach(func(k, _ []byte) error {
+				result = append(result, string(k))
+				return nil
+			})
+			continue
+		}
+		var filtered []string
+		for _, k := range result {
+			if keys.Get([]byte(k)) != nil {
+				filtered = append(filtered, k)
+			}
+		}
+		result = filtered
+	}
+	sort.Strings(result)
+	return result
+}
+
+// entryMatches returns done tasks and events of the entry which have all words.
+// Only done items are matched, because today items repeat until they are done.
+func entryMatches(entry *Entry, queryWords []string) []Match {
+	var matches []Match
+	for i := range entry.Report.Done.Tasks {
+		task := &entry.Report.Done.Tasks[i]
+		if len(task.Notes) > 0 && hasWords(taskWords(task), queryWords) {
+			matches = append(matches, Match{CreatedAt: entry.CreatedAt, Task: task, Notes: matchedNotes(task.Notes, queryWords)})
+		}
+	}
+	for i := range entry.Report.Done.Events {
+		event := &entry.Report.Done.Events[i]
+		if hasWords(words(event.Summary), queryWords) {
+			matches = append(matches, Match{CreatedAt: entry.CreatedAt, Event: event})
+		}
+	}
+	return matches
+}
+
+func matchedNotes(notes []string, queryWords []string) []string {
+	var result []string
+	for _, note := range notes {
+		noteWords := words(note)
+		for _, w := range queryWords {
+			if hasWords(noteWords, []string{w}) {
+				result = append(result, note)
+				break
+			}
+		}
+	}
+	if len(result) < 1 {
+		return notes
+	}
+	return result
+}
+
+func hasWords(have, want []string) bool {
+	set := make(map[string]bool, len(have))
+	for _, w := range have {
+		set[w] = true
+	}
+	for _, w := range want {
+		if !set[w] {
+			return false
+		}
+	}
+	return true
+}
+
+// reportWords returns unique words of done tasks and events
+func reportWords(report *models.Report) []string {
+	var all []string
+	for i := range report.Done.Tasks {
+		if len(report.Done.Tasks[i].Notes) > 0 {
+			all = append(all, taskWords(&report.Done.Tasks[i])...)
+		}
+	}
+	for _, event := range report.Done.Events {
+		all = append(all, words(event.Summary)...)
+	}
+
+	seen := make(map[string]bool)
+	var result []string
+	for _, w := range all {
+		if !seen[w] {
+			seen[w] = true
+			result = append(result, w)
+		}
+	}
+	return result
+}
+
+func taskWords(task *models.Task) []string {
+	text := task.Title + " " + strings.Join(task.Projects, " ") + " " + strings.Join(task.Notes, " ")
+	return words(text)
+}
+
+// words splits text to lower case words of letters and digits
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
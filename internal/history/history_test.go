@@ -0,0 +1,187 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/nemca/taskgram/pkg/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "history", "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func billingReport(notes ...string) *models.Report {
+	return &models.Report{
+		Targets: []string{"jira"},
+		Done: models.Items{Tasks: models.Tasks{
+			{Title: "ACME-1 Billing", URL: "https://jira.example.com/browse/ACME-1", Projects: []string{"Payments"}, Notes: notes},
+		}},
+		// Today items are not indexed
+		Today: models.Items{Tasks: models.Tasks{{Title: "ACME-2 Reports", Notes: []string{"Draft"}}}},
+	}
+}
+
+func standup(start time.Time) models.Event {
+	return models.Event{Summary: "Daily standup", Start: start, End: start.Add(15 * time.Minute)}
+}
+
+// indexedWords returns sorted words of the index and checks
+// that every indexed key refers to a stored report
+func indexedWords(t *testing.T, s *Store) []string {
+	t.Helper()
+	var result []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		reports := tx.Bucket(reportsBucket)
+		return tx.Bucket(wordsBucket).ForEach(func(word, _ []byte) error {
+			result = append(result, string(word))
+			return tx.Bucket(wordsBucket).Bucket(word).ForEach(func(k, _ []byte) error {
+				if reports.Get(k) == nil {
+					t.Errorf("word %q refers to missing report %s", word, k)
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func TestAddReplacesReportOfTheSameDay(t *testing.T) {
+	s := openTestStore(t)
+	morning := time.Date(2026, 1, 5, 9, 0, 0, 0, time.Local)
+
+	first := billingReport("Fixed rounding")
+	first.Done.Events = models.Events{standup(morning.Add(-time.Hour))}
+	if err := s.add(first, morning); err != nil {
+		t.Fatal(err)
+	}
+	second := billingReport("Fixed rounding", "Added invoices")
+	if err := s.add(second, morning.Add(9*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || !entries[0].CreatedAt.Equal(morning.Add(9*time.Hour)) || !reflect.DeepEqual(entries[0].Report, *second) {
+		t.Fatalf("List() = %+v, want the second report only", entries)
+	}
+
+	// Words of the replaced report are removed from the index
+	want := []string{"1", "acme", "added", "billing", "fixed", "invoices", "payments", "rounding"}
+	if got := indexedWords(t, s); !reflect.DeepEqual(got, want) {
+		t.Errorf("indexed words = %q, want %q", got, want)
+	}
+	matches, err := s.Search("standup")
+	if err != nil || len(matches) > 0 {
+		t.Errorf("Search(standup) = %+v, %v, want no matches", matches, err)
+	}
+
+	// The next day keeps the report of the previous one
+	if err := s.add(billingReport("Released"), morning.Add(24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := s.List(); len(entries) != 2 {
+		t.Errorf("got %d reports after the next day, want 2", len(entries))
+	}
+	if entry, err := s.Day(morning); err != nil || !entry.CreatedAt.Equal(morning.Add(9*time.Hour)) {
+		t.Errorf("Day() = %+v, %v", entry, err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	s := openTestStore(t)
+	day1 := time.Date(2026, 1, 5, 18, 0, 0, 0, time.Local)
+	day2 := day1.Add(24 * time.Hour)
+
+	report1 := billingReport("Fixed rounding", "Added invoices")
+	report1.Done.Events = models.Events{standup(day1.Add(-8 * time.Hour))}
+	// The second report repeats the task notes and the event of the first one,
+	// e.g. after a weekend
+	report2 := billingReport("Fixed rounding", "Added invoices")
+	report2.Done.Events = models.Events{standup(day1.Add(-8 * time.Hour)), standup(day2.Add(-8 * time.Hour))}
+	for i, r := range []*models.Report{report1, report2} {
+		if err := s.add(r, day1.Add(time.Duration(i)*24*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	task := &report1.Done.Tasks[0]
+	tests := []struct {
+		query string
+		want  []Match
+	}{
+		// Only the notes with query words are returned
+		{query: "Invoices", want: []Match{{CreatedAt: day1, Task: task, Notes: []string{"Added invoices"}}}},
+		// All notes are returned if only the title matches
+		{query: "billing acme-1", want: []Match{{CreatedAt: day1, Task: task, Notes: task.Notes}}},
+		{query: "payments rounding", want: []Match{{CreatedAt: day1, Task: task, Notes: []string{"Fixed rounding"}}}},
+		{query: "daily standup", want: []Match{
+			{CreatedAt: day1, Event: &report1.Done.Events[0]},
+			{CreatedAt: day2, Event: &report2.Done.Events[1]},
+		}},
+		// Words of different items of the same report
+		{query: "billing standup"},
+		{query: "retro"},
+		{query: "draft"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := s.Search(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Search() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].CreatedAt.Equal(tt.want[i].CreatedAt) {
+					t.Errorf("match %d CreatedAt = %s, want %s", i, got[i].CreatedAt, tt.want[i].CreatedAt)
+				}
+				got[i].CreatedAt = tt.want[i].CreatedAt
+				if tt.want[i].Event != nil && got[i].Event != nil && got[i].Event.Start.Equal(tt.want[i].Event.Start) {
+					got[i].Event.Start, got[i].Event.End = tt.want[i].Event.Start, tt.want[i].Event.End
+				}
+				if !reflect.DeepEqual(got[i], tt.want[i]) {
+					t.Errorf("match %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	if _, err := s.Search(" - "); err == nil {
+		t.Error("Search() of an empty query succeeded")
+	}
+}
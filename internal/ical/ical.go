@@ -0,0 +1,319 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ical implements parsing of iCalendar (RFC 5545) events
+// and expansion of recurring events.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	dateLayout        = "20060102"
+	dateTimeLayout    = "20060102T150405"
+	dateTimeUTCLayout = "20060102T150405Z"
+)
+
+// Event represents a VEVENT component
+type Event struct {
+	UID     string
+	Summary string
+	Status  string
+	Start   time.Time
+	End     time.Time
+	// AllDay is true when DTSTART is a date without time
+	AllDay bool
+	// RRule is a recurrence rule, nil for single events
+	RRule *RRule
+	// ExDates are start times of excluded recurrence instances
+	ExDates []time.Time
+	// RecurrenceID is the original start time of a recurrence instance
+	// overridden by this event, zero for other events
+	RecurrenceID time.Time
+}
+
+// property is a content line of iCalendar object
+type property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Parse reads VEVENT components from iCalendar data.
+func Parse(r io.Reader) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	var event *Event
+	var duration time.Duration
+	var hasDuration bool
+	// depth of nested components inside VEVENT, e.g. VALARM
+	nested := 0
+
+	for n, line := range lines {
+		if len(strings.TrimSpace(line)) < 1 {
+			continue
+		}
+		prop, err := parseProperty(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+1, err)
+		}
+
+		switch {
+		case prop.Name == "BEGIN" && strings.EqualFold(prop.Value, "VEVENT") && event == nil:
+			event = &Event{}
+			duration, hasDuration = 0, false
+			continue
+		case prop.Name == "END" && strings.EqualFold(prop.Value, "VEVENT") && nested == 0 && event != nil:
+			if event.End.IsZero() {
+				switch {
+				case hasDuration:
+					event.End = event.Start.Add(duration)
+				case event.AllDay:
+					event.End = event.Start.AddDate(0, 0, 1)
+				default:
+					event.End = event.Start
+				}
+			}
+			if !event.Start.IsZero() {
+				events = append(events, *event)
+			}
+			event = nil
+			continue
+		case event == nil:
+			continue
+		case prop.Name == "BEGIN":
+			nested++
+			continue
+		case prop.Name == "END":
+			nested--
+			continue
+		case nested > 0:
+			continue
+		}
+
+		switch prop.Name {
+		case "UID":
+			event.UID = prop.Value
+		case "SUMMARY":
+			event.Summary = unescapeText(prop.Value)
+		case "STATUS":
+			event.Status = strings.ToUpper(prop.Value)
+		case "DTSTART":
+			event.Start, event.AllDay, err = parseDateTime(prop)
+		case "DTEND":
+			event.End, _, err = parseDateTime(prop)
+		case "DURATION":
+			duration, err = parseDuration(prop.Value)
+			hasDuration = err == nil
+		case "RRULE":
+			event.RRule, err = ParseRRule(prop.Value)
+		case "EXDATE":
+			var dates []time.Time
+			dates, err = parseDateTimeList(prop)
+			event.ExDates = append(event.ExDates, dates...)
+		case "RECURRENCE-ID":
+			event.RecurrenceID, _, err = parseDateTime(prop)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %v", n+1, prop.Name, err)
+		}
+	}
+
+	return events, nil
+}
+
+// unfold reads content lines joining folded lines.
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// parseProperty parses a content line "NAME;PARAM=VALUE:VALUE".
+func parseProperty(line string) (property, error) {
+	prop := property{Params: make(map[string]string)}
+
+	// Find the value delimiter outside quoted parameter values
+	quoted := false
+	colon := -1
+	for i, c := range line {
+		if c == '"' {
+			quoted = !quoted
+		}
+		if c == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return prop, fmt.Errorf("invalid content line %q", line)
+	}
+	prop.Value = line[colon+1:]
+
+	parts := strings.Split(line[:colon], ";")
+	prop.Name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		prop.Params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+	}
+
+	return prop, nil
+}
+
+// parseDateTime parses DATE or DATE-TIME value of the property
+// using TZID parameter for local times.
+func parseDateTime(prop property) (t time.Time, allDay bool, err error) {
+	return parseDateTimeValue(prop.Value, prop.Params)
+}
+
+func parseDateTimeList(prop property) ([]time.Time, error) {
+	var dates []time.Time
+	for _, v := range strings.Split(prop.Value, ",") {
+		t, _, err := parseDateTimeValue(v, prop.Params)
+		if err != nil {
+			return nil, err
+		}
+		dates = append(dates, t)
+	}
+	return dates, nil
+}
+
+func parseDateTimeValue(value string, params map[string]string) (time.Time, bool, error) {
+	value = strings.TrimSpace(value)
+	loc := location(params["TZID"])
+
+	if params["VALUE"] == "DATE" || len(value) == len(dateLayout) {
+		t, err := time.ParseInLocation(dateLayout, value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(dateTimeUTCLayout, value)
+		return t, false, err
+	}
+	t, err := time.ParseInLocation(dateTimeLayout, value, loc)
+	return t, false, err
+}
+
+// location returns location by TZID, local time for floating and unknown time zones.
+func location(tzid string) *time.Location {
+	if len(tzid) < 1 {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(strings.TrimPrefix(tzid, "/"))
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// parseDuration parses duration value like "PT1H30M", "P1D" or "-PT15M".
+func parseDuration(s string) (time.Duration, error) {
+	orig := s
+	neg := false
+	switch {
+	case strings.HasPrefix(s, "-"):
+		neg = true
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") {
+		return 0, fmt.Errorf("invalid duration %q", orig)
+	}
+	s = s[1:]
+
+	var d time.Duration
+	inTime := false
+	num := 0
+	hasNum := false
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			num = num*10 + int(c-'0')
+			hasNum = true
+			continue
+		case c == 'T':
+			inTime = true
+			continue
+		}
+		if !hasNum {
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+		switch {
+		case c == 'W' && !inTime:
+			d += time.Duration(num) * 7 * 24 * time.Hour
+		case c == 'D' && !inTime:
+			d += time.Duration(num) * 24 * time.Hour
+		case c == 'H' && inTime:
+			d += time.Duration(num) * time.Hour
+		case c == 'M' && inTime:
+			d += time.Duration(num) * time.Minute
+		case c == 'S' && inTime:
+			d += time.Duration(num) * time.Second
+		default:
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+		num, hasNum = 0, false
+	}
+	if hasNum {
+		return 0, fmt.Errorf("invalid duration %q", orig)
+	}
+
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
+
+// unescapeText unescapes TEXT value.
+func unescapeText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
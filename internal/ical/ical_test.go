@@ -0,0 +1,155 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ical
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata" // don't depend on the system time zone database
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestParse(t *testing.T) {
+	data := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"UID:folded",
+		"SUMMARY:Planning\\, sprint 42 with a very long summary which is folded",
+		"  at 75 octets",
+		`LOCATION;ALTREP="http://example.com/a:b":Room 1`,
+		"DTSTART:20260105T090000Z",
+		"DURATION:PT1H30M",
+		"BEGIN:VALARM",
+		"TRIGGER:-PT15M",
+		"DTSTART:20000101T000000Z",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:all-day",
+		"SUMMARY:Offsite",
+		"DTSTART;VALUE=DATE:20260110",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:summer",
+		"SUMMARY:Berlin summer",
+		"DTSTART;TZID=Europe/Berlin:20260706T090000",
+		"DTEND;TZID=Europe/Berlin:20260706T100000",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:winter",
+		"SUMMARY:Berlin winter",
+		"DTSTART;TZID=Europe/Berlin:20260105T090000",
+		"DTEND;TZID=Europe/Berlin:20260105T100000",
+		"STATUS:cancelled",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	events, err := Parse(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	berlin := mustLocation(t, "Europe/Berlin")
+	want := []Event{
+		{
+			UID:     "folded",
+			Summary: "Planning, sprint 42 with a very long summary which is folded at 75 octets",
+			Start:   time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC),
+			End:     time.Date(2026, 1, 5, 10, 30, 0, 0, time.UTC),
+		},
+		{
+			UID:     "all-day",
+			Summary: "Offsite",
+			Start:   time.Date(2026, 1, 10, 0, 0, 0, 0, time.Local),
+			End:     time.Date(2026, 1, 11, 0, 0, 0, 0, time.Local),
+			AllDay:  true,
+		},
+		{
+			UID:     "summer",
+			Summary: "Berlin summer",
+			Start:   time.Date(2026, 7, 6, 9, 0, 0, 0, berlin),
+			End:     time.Date(2026, 7, 6, 10, 0, 0, 0, berlin),
+		},
+		{
+			UID:     "winter",
+			Summary: "Berlin winter",
+			Status:  "CANCELLED",
+			Start:   time.Date(2026, 1, 5, 9, 0, 0, 0, berlin),
+			End:     time.Date(2026, 1, 5, 10, 0, 0, 0, berlin),
+		},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("Parse() =\n%+v\nwant\n%+v", events, want)
+	}
+
+	// Berlin is UTC+2 in summer and UTC+1 in winter
+	if h := events[2].Start.UTC().Hour(); h != 7 {
+		t.Errorf("summer event starts at %d UTC, want 7", h)
+	}
+	if h := events[3].Start.UTC().Hour(); h != 8 {
+		t.Errorf("winter event starts at %d UTC, want 8", h)
+	}
+}
+
+func TestParseInvalidLine(t *testing.T) {
+	_, err := Parse(strings.NewReader("BEGIN:VEVENT\r\nDTSTART:2026-01-05\r\nEND:VEVENT"))
+	if err == nil || !strings.Contains(err.Error(), "line 2: DTSTART") {
+		t.Errorf("Parse() error = %v, want DTSTART error on line 2", err)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{in: "PT15M", want: 15 * time.Minute},
+		{in: "PT1H30M", want: 90 * time.Minute},
+		{in: "P1D", want: 24 * time.Hour},
+		{in: "P1W", want: 7 * 24 * time.Hour},
+		{in: "P1DT2H", want: 26 * time.Hour},
+		{in: "-PT15M", want: -15 * time.Minute},
+		{in: "+PT10S", want: 10 * time.Second},
+		{in: "PT", want: 0},
+		{in: "1H", err: true},
+		{in: "P1H", err: true},
+		{in: "PT1D", err: true},
+		{in: "PT5", err: true},
+	}
+	for _, tt := range tests {
+		got, err := parseDuration(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("parseDuration(%q) error = %v, want error %v", tt.in, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseDuration(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,415 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ical
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence frequencies
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+// maxPeriods limits expansion of rules without COUNT and UNTIL
+const maxPeriods = 100000
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// WeekdayNum is a BYDAY value, e.g. "MO" or "-1FR"
+type WeekdayNum struct {
+	// N is the occurrence of the weekday in the month or year, 0 means every
+	N   int
+	Day time.Weekday
+}
+
+// RRule represents a recurrence rule.
+// Rules with frequency less than daily are not supported.
+type RRule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+	WeekStart  time.Weekday
+}
+
+// ParseRRule parses RRULE value like "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE".
+func ParseRRule(s string) (*RRule, error) {
+	rule := &RRule{Interval: 1, WeekStart: time.Monday}
+
+	for _, part := range strings.Split(s, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, value := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+
+		var err error
+		switch key {
+		case "FREQ":
+			switch value {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+				rule.Freq = value
+			default:
+				return nil, fmt.Errorf("unsupported frequency %q", value)
+			}
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(value)
+			if err == nil && rule.Interval < 1 {
+				err = fmt.Errorf("invalid interval %q", value)
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(value)
+		case "UNTIL":
+			rule.Until, _, err = parseDateTimeValue(value, nil)
+		case "BYDAY":
+			for _, v := range strings.Split(value, ",") {
+				var wd WeekdayNum
+				wd, err = parseWeekdayNum(v)
+				if err != nil {
+					break
+				}
+				rule.ByDay = append(rule.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			for _, v := range strings.Split(value, ",") {
+				var day int
+				day, err = strconv.Atoi(v)
+				if err != nil {
+					break
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, day)
+			}
+		case "BYMONTH":
+			for _, v := range strings.Split(value, ",") {
+				var month int
+				month, err = strconv.Atoi(v)
+				if err != nil {
+					break
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(month))
+			}
+		case "WKST":
+			day, ok := weekdays[value]
+			if !ok {
+				err = fmt.Errorf("invalid weekday %q", value)
+			}
+			rule.WeekStart = day
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %v", key, err)
+		}
+	}
+
+	if len(rule.Freq) < 1 {
+		return nil, fmt.Errorf("FREQ is required in %q", s)
+	}
+
+	return rule, nil
+}
+
+func parseWeekdayNum(s string) (WeekdayNum, error) {
+	if len(s) < 2 {
+		return WeekdayNum{}, fmt.Errorf("invalid weekday %q", s)
+	}
+	day, ok := weekdays[s[len(s)-2:]]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("invalid weekday %q", s)
+	}
+	wd := WeekdayNum{Day: day}
+	if n := s[:len(s)-2]; len(n) > 0 {
+		var err error
+		wd.N, err = strconv.Atoi(strings.TrimPrefix(n, "+"))
+		if err != nil {
+			return WeekdayNum{}, fmt.Errorf("invalid weekday %q", s)
+		}
+	}
+	return wd, nil
+}
+
+// Expand returns single events and instances of recurring events
+// overlapping the [start, end) window sorted by start time.
+// Cancelled events, EXDATE instances and instances overridden
+// by events with RECURRENCE-ID are skipped.
+func Expand(events []Event, start, end time.Time) []Event {
+	// Original start times of overridden instances by UID
+	overridden := make(map[string][]time.Time)
+	for _, e := range events {
+		if !e.RecurrenceID.IsZero() {
+			overridden[e.UID] = append(overridden[e.UID], e.RecurrenceID)
+		}
+	}
+
+	var result []Event
+	for _, e := range events {
+		if e.Status == "CANCELLED" {
+			continue
+		}
+		if e.RRule == nil || !e.RecurrenceID.IsZero() {
+			if overlaps(e, start, end) {
+				result = append(result, e)
+			}
+			continue
+		}
+
+		duration := e.End.Sub(e.Start)
+		for _, t := range e.RRule.Occurrences(e.Start, end) {
+			if containsTime(e.ExDates, t) || containsTime(overridden[e.UID], t) {
+				continue
+			}
+			instance := e
+			instance.Start = t
+			instance.End = t.Add(duration)
+			instance.RRule = nil
+			instance.ExDates = nil
+			if overlaps(instance, start, end) {
+				result = append(result, instance)
+			}
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Start.Before(result[j].Start)
+	})
+
+	return result
+}
+
+// Occurrences returns start times of the rule instances from dtstart until end.
+// The first instance is always dtstart, as required by RFC 5545.
+func (r *RRule) Occurrences(dtstart, end time.Time) []time.Time {
+	var result []time.Time
+	count := 0
+
+	// add returns false when the rule is exhausted
+	add := func(t time.Time) bool {
+		if r.Count > 0 && count >= r.Count {
+			return false
+		}
+		if !r.Until.IsZero() && t.After(r.Until) {
+			return false
+		}
+		count++
+		if t.Before(end) {
+			result = append(result, t)
+		}
+		return true
+	}
+
+	if !add(dtstart) {
+		return result
+	}
+
+	for period := 0; period < maxPeriods; period++ {
+		candidates := r.candidates(dtstart, period)
+		if len(candidates) < 1 && r.periodStart(dtstart, period).After(end) {
+			break
+		}
+		for _, t := range candidates {
+			if !t.After(dtstart) {
+				continue
+			}
+			if !t.Before(end) {
+				return result
+			}
+			if !add(t) {
+				return result
+			}
+		}
+	}
+
+	return result
+}
+
+// periodStart returns the first day of the period.
+func (r *RRule) periodStart(dtstart time.Time, period int) time.Time {
+	y, m, d := dtstart.Date()
+	n := period * r.Interval
+	switch r.Freq {
+	case FreqDaily:
+		return dateAt(dtstart, y, m, d+n)
+	case FreqWeekly:
+		offset := (int(dtstart.Weekday()) - int(r.WeekStart) + 7) % 7
+		return dateAt(dtstart, y, m, d-offset+7*n)
+	case FreqMonthly:
+		return dateAt(dtstart, y, m+time.Month(n), 1)
+	default:
+		return dateAt(dtstart, y+n, time.January, 1)
+	}
+}
+
+// candidates returns sorted instance times in the period.
+func (r *RRule) candidates(dtstart time.Time, period int) []time.Time {
+	ps := r.periodStart(dtstart, period)
+	var days []time.Time
+
+	switch r.Freq {
+	case FreqDaily:
+		if r.matchMonth(ps) && r.matchMonthDay(ps) && r.matchWeekday(ps) {
+			days = append(days, ps)
+		}
+	case FreqWeekly:
+		for i := 0; i < 7; i++ {
+			day := ps.AddDate(0, 0, i)
+			if len(r.ByDay) < 1 && day.Weekday() != dtstart.Weekday() {
+				continue
+			}
+			if r.matchMonth(day) && r.matchWeekday(day) {
+				days = append(days, day)
+			}
+		}
+	case FreqMonthly:
+		if r.matchMonth(ps) {
+			days = r.monthDays(dtstart, ps.Year(), ps.Month())
+		}
+	case FreqYearly:
+		months := r.ByMonth
+		if len(months) < 1 {
+			months = []time.Month{dtstart.Month()}
+		}
+		for _, m := range months {
+			days = append(days, r.monthDays(dtstart, ps.Year(), m)...)
+		}
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
+
+// monthDays returns instance days of the month for monthly and yearly rules.
+func (r *RRule) monthDays(dtstart time.Time, year int, month time.Month) []time.Time {
+	last := dateAt(dtstart, year, month+1, 0).Day()
+	var days []time.Time
+
+	switch {
+	case len(r.ByDay) > 0:
+		for d := 1; d <= last; d++ {
+			day := dateAt(dtstart, year, month, d)
+			if r.matchWeekdayInMonth(day, last) && r.matchMonthDay(day) {
+				days = append(days, day)
+			}
+		}
+	case len(r.ByMonthDay) > 0:
+		for d := 1; d <= last; d++ {
+			day := dateAt(dtstart, year, month, d)
+			if r.matchMonthDay(day) {
+				days = append(days, day)
+			}
+		}
+	default:
+		// Months without the day of dtstart are skipped
+		if dtstart.Day() <= last {
+			days = append(days, dateAt(dtstart, year, month, dtstart.Day()))
+		}
+	}
+
+	return days
+}
+
+func (r *RRule) matchMonth(t time.Time) bool {
+	if len(r.ByMonth) < 1 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if t.Month() == m {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RRule) matchMonthDay(t time.Time) bool {
+	if len(r.ByMonthDay) < 1 {
+		return true
+	}
+	last := dateAt(t, t.Year(), t.Month()+1, 0).Day()
+	for _, d := range r.ByMonthDay {
+		if d == t.Day() || (d < 0 && last+d+1 == t.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RRule) matchWeekday(t time.Time) bool {
+	if len(r.ByDay) < 1 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Day == t.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWeekdayInMonth checks BYDAY with optional ordinal, e.g. "2TU" or "-1FR".
+func (r *RRule) matchWeekdayInMonth(t time.Time, lastDay int) bool {
+	for _, wd := range r.ByDay {
+		if wd.Day != t.Weekday() {
+			continue
+		}
+		switch {
+		case wd.N == 0:
+			return true
+		case wd.N > 0 && (t.Day()-1)/7+1 == wd.N:
+			return true
+		case wd.N < 0 && (lastDay-t.Day())/7+1 == -wd.N:
+			return true
+		}
+	}
+	return false
+}
+
+// dateAt returns the date with time of day and location of t.
+func dateAt(t time.Time, year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, t.Hour(), t.Minute(), t.Second(), 0, t.Location())
+}
+
+func overlaps(e Event, start, end time.Time) bool {
+	if e.End.After(e.Start) {
+		return e.Start.Before(end) && e.End.After(start)
+	}
+	return !e.Start.Before(start) && e.Start.Before(end)
+}
+
+func containsTime(list []time.Time, t time.Time) bool {
+	for _, item := range list {
+		if item.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
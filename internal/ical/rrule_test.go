@@ -0,0 +1,247 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ical
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRRule(t *testing.T) {
+	rule, err := ParseRRule("FREQ=MONTHLY;INTERVAL=2;COUNT=5;BYDAY=2TU,-1FR,+3MO;BYMONTHDAY=1,-1;BYMONTH=3;WKST=SU")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &RRule{
+		Freq:     FreqMonthly,
+		Interval: 2,
+		Count:    5,
+		ByDay: []WeekdayNum{
+			{N: 2, Day: time.Tuesday},
+			{N: -1, Day: time.Friday},
+			{N: 3, Day: time.Monday},
+		},
+		ByMonthDay: []int{1, -1},
+		ByMonth:    []time.Month{time.March},
+		WeekStart:  time.Sunday,
+	}
+	if !reflect.DeepEqual(rule, want) {
+		t.Errorf("ParseRRule() = %+v, want %+v", rule, want)
+	}
+
+	for _, s := range []string{"COUNT=3", "FREQ=HOURLY", "FREQ=DAILY;INTERVAL=0", "FREQ=WEEKLY;BYDAY=XX", "FREQ=WEEKLY;UNTIL=tomorrow"} {
+		if _, err := ParseRRule(s); err == nil {
+			t.Errorf("ParseRRule(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	berlin := mustLocation(t, "Europe/Berlin")
+	newYork := mustLocation(t, "America/New_York")
+	day := func(loc *time.Location, month time.Month, day, hour int) time.Time {
+		return time.Date(2026, month, day, hour, 0, 0, 0, loc)
+	}
+	windowEnd := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart time.Time
+		end     time.Time
+		want    []time.Time
+	}{
+		{
+			name:    "daily count",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: day(time.UTC, 1, 5, 9),
+			end:     windowEnd,
+			want:    []time.Time{day(time.UTC, 1, 5, 9), day(time.UTC, 1, 6, 9), day(time.UTC, 1, 7, 9)},
+		},
+		{
+			name:    "count includes instances before the window end",
+			rule:    "FREQ=DAILY;COUNT=10",
+			dtstart: day(time.UTC, 1, 5, 9),
+			end:     day(time.UTC, 1, 7, 0),
+			want:    []time.Time{day(time.UTC, 1, 5, 9), day(time.UTC, 1, 6, 9)},
+		},
+		{
+			name:    "weekly until is inclusive",
+			rule:    "FREQ=WEEKLY;UNTIL=20260126T090000Z",
+			dtstart: day(time.UTC, 1, 5, 9),
+			end:     windowEnd,
+			want:    []time.Time{day(time.UTC, 1, 5, 9), day(time.UTC, 1, 12, 9), day(time.UTC, 1, 19, 9), day(time.UTC, 1, 26, 9)},
+		},
+		{
+			name:    "biweekly on several days",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;COUNT=4",
+			dtstart: day(time.UTC, 1, 5, 9),
+			end:     windowEnd,
+			want:    []time.Time{day(time.UTC, 1, 5, 9), day(time.UTC, 1, 7, 9), day(time.UTC, 1, 19, 9), day(time.UTC, 1, 21, 9)},
+		},
+		{
+			name:    "second tuesday of the month",
+			rule:    "FREQ=MONTHLY;BYDAY=2TU;COUNT=3",
+			dtstart: day(time.UTC, 1, 13, 10),
+			end:     windowEnd,
+			want:    []time.Time{day(time.UTC, 1, 13, 10), day(time.UTC, 2, 10, 10), day(time.UTC, 3, 10, 10)},
+		},
+		{
+			name:    "last friday of the month",
+			rule:    "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3",
+			dtstart: day(time.UTC, 1, 30, 16),
+			end:     windowEnd,
+			want:    []time.Time{day(time.UTC, 1, 30, 16), day(time.UTC, 2, 27, 16), day(time.UTC, 3, 27, 16)},
+		},
+		{
+			name:    "last day of the month",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=-1;UNTIL=20260501T000000Z",
+			dtstart: day(time.UTC, 1, 31, 12),
+			end:     windowEnd,
+			want:    []time.Time{day(time.UTC, 1, 31, 12), day(time.UTC, 2, 28, 12), day(time.UTC, 3, 31, 12), day(time.UTC, 4, 30, 12)},
+		},
+		{
+			name:    "yearly",
+			rule:    "FREQ=YEARLY;COUNT=2",
+			dtstart: day(time.UTC, 3, 1, 0),
+			end:     time.Date(2028, 1, 1, 0, 0, 0, 0, time.UTC),
+			want:    []time.Time{day(time.UTC, 3, 1, 0), time.Date(2027, 3, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:    "daily across the spring DST change",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: day(berlin, 3, 28, 9),
+			end:     windowEnd,
+			want:    []time.Time{day(berlin, 3, 28, 9), day(berlin, 3, 29, 9), day(berlin, 3, 30, 9)},
+		},
+		{
+			name:    "weekly across the autumn DST change",
+			rule:    "FREQ=WEEKLY;BYDAY=TH;COUNT=2",
+			dtstart: day(newYork, 10, 29, 9),
+			end:     windowEnd,
+			want:    []time.Time{day(newYork, 10, 29, 9), day(newYork, 11, 5, 9)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRRule(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := rule.Occurrences(tt.dtstart, tt.end)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Occurrences() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOccurrencesKeepLocalTimeAcrossDST(t *testing.T) {
+	berlin := mustLocation(t, "Europe/Berlin")
+	rule, err := ParseRRule("FREQ=DAILY;COUNT=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := rule.Occurrences(time.Date(2026, 3, 28, 9, 0, 0, 0, berlin), time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC))
+	if len(got) != 2 {
+		t.Fatalf("got %d occurrences, want 2", len(got))
+	}
+	// 09:00 CET is 08:00 UTC, 09:00 CEST is 07:00 UTC
+	if h := got[0].UTC().Hour(); h != 8 {
+		t.Errorf("first instance at %d UTC, want 8", h)
+	}
+	if h := got[1].UTC().Hour(); h != 7 {
+		t.Errorf("second instance at %d UTC, want 7", h)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	data := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"UID:standup",
+		"SUMMARY:Standup",
+		"DTSTART;TZID=Europe/Berlin:20260105T100000",
+		"DTEND;TZID=Europe/Berlin:20260105T101500",
+		"RRULE:FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR",
+		"EXDATE;TZID=Europe/Berlin:20260107T100000",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:standup",
+		"SUMMARY:Standup moved",
+		"RECURRENCE-ID;TZID=Europe/Berlin:20260108T100000",
+		"DTSTART;TZID=Europe/Berlin:20260108T150000",
+		"DTEND;TZID=Europe/Berlin:20260108T151500",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:holiday",
+		"SUMMARY:Holiday",
+		"DTSTART;VALUE=DATE:20260107",
+		"DTEND;VALUE=DATE:20260108",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:cancelled",
+		"SUMMARY:Cancelled",
+		"STATUS:CANCELLED",
+		"DTSTART;TZID=Europe/Berlin:20260106T120000",
+		"DTEND;TZID=Europe/Berlin:20260106T130000",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:outside",
+		"SUMMARY:Outside",
+		"DTSTART;TZID=Europe/Berlin:20260201T120000",
+		"DTEND;TZID=Europe/Berlin:20260201T130000",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	events, err := Parse(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	berlin := mustLocation(t, "Europe/Berlin")
+	start := time.Date(2026, 1, 6, 0, 0, 0, 0, berlin)
+	end := time.Date(2026, 1, 10, 0, 0, 0, 0, berlin)
+	expanded := Expand(events, start, end)
+
+	type instance struct {
+		summary string
+		start   time.Time
+		allDay  bool
+	}
+	var got []instance
+	for _, e := range expanded {
+		if e.RRule != nil || e.ExDates != nil {
+			t.Errorf("instance %q keeps recurrence data", e.Summary)
+		}
+		got = append(got, instance{summary: e.Summary, start: e.Start, allDay: e.AllDay})
+	}
+	want := []instance{
+		{summary: "Standup", start: time.Date(2026, 1, 6, 10, 0, 0, 0, berlin)},
+		// The all-day event starts at local midnight
+		{summary: "Holiday", start: time.Date(2026, 1, 7, 0, 0, 0, 0, time.Local), allDay: true},
+		{summary: "Standup moved", start: time.Date(2026, 1, 8, 15, 0, 0, 0, berlin)},
+		{summary: "Standup", start: time.Date(2026, 1, 9, 10, 0, 0, 0, berlin)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expand() =\n%v\nwant\n%v", got, want)
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package registry implements registries of factories by type name,
+// e.g. sources by target type and sinks by sink type.
+package registry
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Registry is a set of factories by type name safe for concurrent use
+type Registry[F any] struct {
+	// kind is used in messages, e.g. target or sink
+	kind      string
+	mu        sync.RWMutex
+	factories map[string]F
+}
+
+// New returns an empty registry of the kind of types.
+func New[F any](kind string) *Registry[F] {
+	return &Registry[F]{kind: kind, factories: make(map[string]F)}
+}
+
+// Register makes the factory available by the type.
+// If Register is called twice with the same type, it panics.
+func (r *Registry[F]) Register(typ string, factory F) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, dup := r.factories[typ]; dup {
+		panic(fmt.Sprintf("%s: Register called twice for type %s", r.kind, typ))
+	}
+	r.factories[typ] = factory
+}
+
+// Get returns the factory by the type.
+func (r *Registry[F]) Get(typ string) (F, error) {
+	r.mu.RLock()
+	factory, ok := r.factories[typ]
+	r.mu.RUnlock()
+	if !ok {
+		return factory, fmt.Errorf("unknown %s type %q, supported types: %v", r.kind, typ, r.Types())
+	}
+	return factory, nil
+}
+
+// Types returns a sorted list of the registered types.
+func (r *Registry[F]) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]string, 0, len(r.factories))
+	for t := range r.factories {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	return types
+}
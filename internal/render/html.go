@@ -0,0 +1,69 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"html"
+	"io"
+	"strings"
+
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+// HTML writes the report as HTML fragment with headings and nested lists.
+func HTML(w io.Writer, report *models.Report) error {
+	ew := &errWriter{w: w}
+	if stale := report.Stale(); len(stale) > 0 {
+		ew.printf("<p><strong>%s</strong></p>\n", html.EscapeString(stale))
+	}
+	for _, s := range sections(report) {
+		ew.printf("<h3>%s</h3>\n<ul>\n", s.Title)
+		for _, t := range withNotes(s.Items.Tasks) {
+			if len(t.URL) > 0 {
+				ew.printf("<li><a href=\"%s\">%s</a>", html.EscapeString(t.URL), html.EscapeString(t.Title))
+			} else {
+				ew.printf("<li>%s", html.EscapeString(t.Title))
+			}
+			for _, p := range t.Projects {
+				ew.printf(" #%s", html.EscapeString(strings.ToLower(p)))
+			}
+			ew.printf("\n<ul>\n")
+			for _, note := range t.Notes {
+				ew.printf("<li>%s</li>\n", html.EscapeString(note))
+			}
+			ew.printf("</ul>\n</li>\n")
+		}
+		if s.Items.Events.EventsLen() > 0 {
+			ew.printf("<li>Meetings\n<ul>\n")
+			for _, e := range s.Items.Events {
+				if len(e.Summary) > 0 {
+					ew.printf("<li>%s</li>\n", html.EscapeString(e.Summary))
+				}
+			}
+			ew.printf("</ul>\n</li>\n")
+		}
+		ew.printf("</ul>\n")
+	}
+	if warnings := report.Warnings(); len(warnings) > 0 {
+		ew.printf("<h3>WARNINGS</h3>\n<ul>\n")
+		for _, warning := range warnings {
+			ew.printf("<li>%s</li>\n", html.EscapeString(warning))
+		}
+		ew.printf("</ul>\n")
+	}
+	return ew.err
+}
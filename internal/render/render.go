@@ -0,0 +1,115 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package render implements output formats of the report.
+package render
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+// Renderer writes the report in some format
+type Renderer interface {
+	Render(w io.Writer, report *models.Report) error
+}
+
+// RendererFunc is an adapter to use ordinary functions as Renderer
+type RendererFunc func(w io.Writer, report *models.Report) error
+
+// Render implements Renderer interface
+func (f RendererFunc) Render(w io.Writer, report *models.Report) error {
+	return f(w, report)
+}
+
+// DefaultFormat is used if format is not set
+const DefaultFormat = "markdown"
+
+var renderers = map[string]Renderer{
+	"markdown": RendererFunc(Markdown),
+	"text":     RendererFunc(Text),
+	"json":     RendererFunc(JSON),
+	"yaml":     RendererFunc(YAML),
+	"html":     RendererFunc(HTML),
+	"slack":    RendererFunc(Slack),
+}
+
+// New returns renderer by format name.
+func New(format string) (Renderer, error) {
+	if len(format) < 1 {
+		format = DefaultFormat
+	}
+	r, ok := renderers[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q, supported formats: %v", format, Formats())
+	}
+	return r, nil
+}
+
+// Formats returns a sorted list of supported formats.
+func Formats() []string {
+	formats := make([]string, 0, len(renderers))
+	for f := range renderers {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// section is a titled part of the report, e.g. YESTERDAY
+type section struct {
+	Title string
+	Items models.Items
+}
+
+// sections returns non-empty sections of the report.
+func sections(report *models.Report) []section {
+	var result []section
+	if !report.Done.Empty() {
+		result = append(result, section{Title: "YESTERDAY", Items: report.Done})
+	}
+	if !report.Today.Empty() {
+		result = append(result, section{Title: "TODAY", Items: report.Today})
+	}
+	return result
+}
+
+// withNotes returns tasks which have notes.
+func withNotes(tasks models.Tasks) models.Tasks {
+	var result models.Tasks
+	for _, t := range tasks {
+		if len(t.Notes) > 0 {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// errWriter remembers the first write error
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, a ...interface{}) {
+	if ew.err != nil {
+		return
+	}
+	_, ew.err = fmt.Fprintf(ew.w, format, a...)
+}
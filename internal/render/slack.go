@@ -0,0 +1,77 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"io"
+	"strings"
+
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// Slack writes the report in Slack mrkdwn format.
+func Slack(w io.Writer, report *models.Report) error {
+	ew := &errWriter{w: w}
+	if stale := report.Stale(); len(stale) > 0 {
+		ew.printf(":warning: %s\n\n", SlackEscape(stale))
+	}
+	for _, s := range sections(report) {
+		ew.printf("*%s:*\n", s.Title)
+		for _, t := range withNotes(s.Items.Tasks) {
+			ew.printf("• %s", SlackLink(t.URL, t.Title))
+			for _, p := range t.Projects {
+				ew.printf(" #%s", SlackEscape(strings.ToLower(p)))
+			}
+			ew.printf("\n")
+			for _, note := range t.Notes {
+				ew.printf("    ◦ %s\n", SlackEscape(note))
+			}
+		}
+		if s.Items.Events.EventsLen() > 0 {
+			ew.printf("• Meetings\n")
+			for _, e := range s.Items.Events {
+				if len(e.Summary) > 0 {
+					ew.printf("    ◦ %s\n", SlackEscape(e.Summary))
+				}
+			}
+		}
+		ew.printf("\n")
+	}
+	if warnings := report.Warnings(); len(warnings) > 0 {
+		ew.printf("*WARNINGS:*\n")
+		for _, warning := range warnings {
+			ew.printf("• %s\n", SlackEscape(warning))
+		}
+		ew.printf("\n")
+	}
+	return ew.err
+}
+
+// SlackEscape escapes control characters of Slack mrkdwn.
+func SlackEscape(s string) string {
+	return slackEscaper.Replace(s)
+}
+
+// SlackLink returns mrkdwn link <url|title> or escaped title if url is empty.
+func SlackLink(url, title string) string {
+	if len(url) < 1 {
+		return SlackEscape(title)
+	}
+	return "<" + url + "|" + SlackEscape(title) + ">"
+}
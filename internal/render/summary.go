@@ -0,0 +1,103 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+// otherProject is the title of tasks without projects in the summary
+const otherProject = "Other"
+
+// SummaryFunc writes the period summary in some format
+type SummaryFunc func(w io.Writer, s *models.Summary) error
+
+var summaryRenderers = map[string]SummaryFunc{
+	"markdown": func(w io.Writer, s *models.Summary) error { return summaryList(w, s, true) },
+	"text":     func(w io.Writer, s *models.Summary) error { return summaryList(w, s, false) },
+	"json":     func(w io.Writer, s *models.Summary) error { return encodeJSON(w, s) },
+	"yaml":     func(w io.Writer, s *models.Summary) error { return encodeYAML(w, s) },
+}
+
+// NewSummary returns summary renderer by format name.
+func NewSummary(format string) (SummaryFunc, error) {
+	if len(format) < 1 {
+		format = DefaultFormat
+	}
+	f, ok := summaryRenderers[format]
+	if !ok {
+		formats := make([]string, 0, len(summaryRenderers))
+		for name := range summaryRenderers {
+			formats = append(formats, name)
+		}
+		sort.Strings(formats)
+		return nil, fmt.Errorf("unknown summary format %q, supported formats: %v", format, formats)
+	}
+	return f, nil
+}
+
+// summaryList writes tasks grouped by projects and meeting totals as lists,
+// markdown links tasks to their URLs
+func summaryList(w io.Writer, s *models.Summary, markdown bool) error {
+	ew := &errWriter{w: w}
+	ew.printf("DONE %s - %s:\n\n", s.Start.Format("2006-01-02"), s.End.Format("2006-01-02"))
+	for _, p := range s.Projects {
+		if len(p.Name) > 0 {
+			ew.printf("#%s\n", strings.ToLower(p.Name))
+		} else {
+			ew.printf("%s\n", otherProject)
+		}
+		for _, t := range p.Tasks {
+			switch {
+			case markdown && len(t.URL) > 0:
+				ew.printf("- [%s](%s)\n", t.Title, t.URL)
+			case len(t.URL) > 0:
+				ew.printf("- %s (%s)\n", t.Title, t.URL)
+			default:
+				ew.printf("- %s\n", t.Title)
+			}
+			for _, note := range t.Notes {
+				ew.printf("  - %s\n", note)
+			}
+		}
+		ew.printf("\n")
+	}
+	if m := s.Meetings; m.Count > 0 {
+		ew.printf("MEETINGS: %d, %.1f hours\n", m.Count, m.Hours)
+		for _, e := range m.Events {
+			title := e.Summary
+			if len(title) < 1 {
+				title = "(no title)"
+			}
+			ew.printf("- %s: %d, %.1f hours\n", title, e.Count, e.Hours)
+		}
+		ew.printf("\n")
+	}
+	if len(s.Warnings) > 0 {
+		ew.printf("WARNINGS:\n")
+		for _, warning := range s.Warnings {
+			ew.printf("- %s\n", warning)
+		}
+		ew.printf("\n")
+	}
+	return ew.err
+}
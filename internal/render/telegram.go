@@ -0,0 +1,82 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"io"
+	"strings"
+
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+var (
+	// Characters which must be escaped in Telegram MarkdownV2 text
+	telegramEscaper = strings.NewReplacer(
+		`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+		"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+		"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+	)
+	// Characters which must be escaped inside (...) part of inline links
+	telegramURLEscaper = strings.NewReplacer(`\`, `\\`, ")", `\)`)
+)
+
+// Telegram writes the report in Telegram MarkdownV2 format.
+func Telegram(w io.Writer, report *models.Report) error {
+	ew := &errWriter{w: w}
+	if stale := report.Stale(); len(stale) > 0 {
+		ew.printf("_%s_\n\n", TelegramEscape(stale))
+	}
+	for _, s := range sections(report) {
+		ew.printf("*%s:*\n", TelegramEscape(s.Title))
+		for _, t := range withNotes(s.Items.Tasks) {
+			if len(t.URL) > 0 {
+				ew.printf("• [%s](%s)", TelegramEscape(t.Title), telegramURLEscaper.Replace(t.URL))
+			} else {
+				ew.printf("• %s", TelegramEscape(t.Title))
+			}
+			for _, p := range t.Projects {
+				ew.printf(" %s", TelegramEscape("#"+strings.ToLower(p)))
+			}
+			ew.printf("\n")
+			for _, note := range t.Notes {
+				ew.printf("    ◦ %s\n", TelegramEscape(note))
+			}
+		}
+		if s.Items.Events.EventsLen() > 0 {
+			ew.printf("• Meetings\n")
+			for _, e := range s.Items.Events {
+				if len(e.Summary) > 0 {
+					ew.printf("    ◦ %s\n", TelegramEscape(e.Summary))
+				}
+			}
+		}
+		ew.printf("\n")
+	}
+	if warnings := report.Warnings(); len(warnings) > 0 {
+		ew.printf("*WARNINGS:*\n")
+		for _, warning := range warnings {
+			ew.printf("• %s\n", TelegramEscape(warning))
+		}
+		ew.printf("\n")
+	}
+	return ew.err
+}
+
+// TelegramEscape escapes special characters of Telegram MarkdownV2.
+func TelegramEscape(s string) string {
+	return telegramEscaper.Replace(s)
+}
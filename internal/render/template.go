@@ -0,0 +1,85 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+//go:embed templates/default.tmpl
+var defaultTemplate string
+
+// templateFuncs are functions available in report templates
+var templateFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"join":  strings.Join,
+	"date": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+}
+
+var markdownTemplate = template.Must(template.New("default").Funcs(templateFuncs).Parse(defaultTemplate))
+
+// ParseTemplate parses the template text with report template functions.
+func ParseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).Parse(text)
+}
+
+// Template renders the report with user-defined text/template
+type Template struct {
+	tmpl *template.Template
+}
+
+// NewTemplate parses text/template file.
+func NewTemplate(path string) (*Template, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read report template: %v", err)
+	}
+
+	tmpl, err := ParseTemplate(filepath.Base(path), string(b))
+	if err != nil {
+		return nil, fmt.Errorf("parse report template: %v", err)
+	}
+
+	return &Template{tmpl: tmpl}, nil
+}
+
+// Render implements Renderer interface
+func (t *Template) Render(w io.Writer, report *models.Report) error {
+	return t.tmpl.Execute(w, report)
+}
+
+// Markdown writes the report as Markdown lists using the built-in default template.
+func Markdown(w io.Writer, report *models.Report) error {
+	return markdownTemplate.Execute(w, report)
+}
+
+// MarkdownItems writes tasks and events as Markdown lists without section title.
+func MarkdownItems(w io.Writer, items *models.Items) error {
+	return markdownTemplate.ExecuteTemplate(w, "items", items)
+}
@@ -0,0 +1,65 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"io"
+	"strings"
+
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+// Text writes the report as plain text without markup.
+func Text(w io.Writer, report *models.Report) error {
+	ew := &errWriter{w: w}
+	if stale := report.Stale(); len(stale) > 0 {
+		ew.printf("%s\n\n", stale)
+	}
+	for _, s := range sections(report) {
+		ew.printf("%s:\n", s.Title)
+		for _, t := range withNotes(s.Items.Tasks) {
+			ew.printf("- %s", t.Title)
+			if len(t.URL) > 0 {
+				ew.printf(" (%s)", t.URL)
+			}
+			for _, p := range t.Projects {
+				ew.printf(" #%s", strings.ToLower(p))
+			}
+			ew.printf("\n")
+			for _, note := range t.Notes {
+				ew.printf("  - %s\n", note)
+			}
+		}
+		if s.Items.Events.EventsLen() > 0 {
+			ew.printf("- Meetings\n")
+			for _, e := range s.Items.Events {
+				if len(e.Summary) > 0 {
+					ew.printf("  - %s\n", e.Summary)
+				}
+			}
+		}
+		ew.printf("\n")
+	}
+	if warnings := report.Warnings(); len(warnings) > 0 {
+		ew.printf("WARNINGS:\n")
+		for _, warning := range warnings {
+			ew.printf("- %s\n", warning)
+		}
+		ew.printf("\n")
+	}
+	return ew.err
+}
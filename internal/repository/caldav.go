@@ -0,0 +1,162 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repository
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/nemca/taskgram/internal/helpers"
+	"github.com/nemca/taskgram/internal/ical"
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+	"github.com/nemca/taskgram/pkg/source"
+)
+
+const caldavTimeLayout = "20060102T150405Z"
+
+const caldavCalendarQuery = `<?xml version="1.0" encoding="utf-8" ?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop>
+    <d:getetag/>
+    <c:calendar-data/>
+  </d:prop>
+  <c:filter>
+    <c:comp-filter name="VCALENDAR">
+      <c:comp-filter name="VEVENT">
+        <c:time-range start="%s" end="%s"/>
+      </c:comp-filter>
+    </c:comp-filter>
+  </c:filter>
+</c:calendar-query>`
+
+func init() {
+	source.Register("caldav", func(target *config.TargetsConfig) (source.Repository, error) {
+		return NewCalDAVRepository(target.Name, &target.CalDAV)
+	})
+}
+
+type CalDAVRepository struct {
+	Client *http.Client
+	Cfg    *config.CalDAVConfig
+	Name   string
+}
+
+type caldavMultistatus struct {
+	Responses []struct {
+		Href      string `xml:"DAV: href"`
+		Propstats []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				CalendarData string `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+func NewCalDAVRepository(name string, cfg *config.CalDAVConfig) (*CalDAVRepository, error) {
+	if len(cfg.URL) < 1 {
+		return nil, fmt.Errorf("caldav_config.url is required")
+	}
+
+	return &CalDAVRepository{
+		Client: &http.Client{},
+		Cfg: &config.CalDAVConfig{
+			URL:      cfg.URL,
+			Username: cfg.Username,
+			Password: cfg.Password,
+			Token:    cfg.Token,
+			Timeout:  cfg.Timeout,
+		},
+		Name: name,
+	}, nil
+}
+
+// Fetch implements source.Repository interface
+func (r *CalDAVRepository) Fetch(ctx context.Context, sc *models.SearchConfig) (done models.Items, today models.Items, err error) {
+	done.Events, today.Events, err = r.GetEvents(ctx, sc)
+	return
+}
+
+// GetEvents returns events in the search window including instances of recurring events.
+func (r *CalDAVRepository) GetEvents(ctx context.Context, sc *models.SearchConfig) (doneEvents models.Events, todayEvents models.Events, err error) {
+	events, err := r.QueryCalendar(ctx, sc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doneEvents, todayEvents = splitEvents(ical.Expand(events, sc.LastEditedTimeStart, sc.LastEditedTimeEnd))
+	return doneEvents, todayEvents, nil
+}
+
+// QueryCalendar sends calendar-query REPORT for VEVENTs in the search window.
+// Recurring events are returned as is, without expansion.
+func (r *CalDAVRepository) QueryCalendar(ctx context.Context, sc *models.SearchConfig) ([]ical.Event, error) {
+	ctx, cancel := helpers.WithTimeout(ctx, r.Cfg.Timeout)
+	defer cancel()
+
+	body := fmt.Sprintf(caldavCalendarQuery,
+		sc.LastEditedTimeStart.UTC().Format(caldavTimeLayout),
+		sc.LastEditedTimeEnd.UTC().Format(caldavTimeLayout))
+	req, err := http.NewRequestWithContext(ctx, "REPORT", r.Cfg.URL, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	req.Header.Set("Depth", "1")
+	if len(r.Cfg.Username) > 0 {
+		req.SetBasicAuth(r.Cfg.Username, r.Cfg.Password)
+	} else if len(r.Cfg.Token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+r.Cfg.Token)
+	}
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("query CalDAV calendar: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("query CalDAV calendar: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+
+	var ms caldavMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("decode CalDAV response: %v", err)
+	}
+
+	var events []ical.Event
+	for _, response := range ms.Responses {
+		for _, ps := range response.Propstats {
+			if len(ps.Prop.CalendarData) < 1 {
+				continue
+			}
+			parsed, err := ical.Parse(strings.NewReader(ps.Prop.CalendarData))
+			if err != nil {
+				return nil, fmt.Errorf("parse %s: %v", response.Href, err)
+			}
+			events = append(events, parsed...)
+		}
+	}
+
+	return events, nil
+}
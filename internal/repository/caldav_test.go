@@ -0,0 +1,214 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+const caldavMultistatusBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:response>
+    <d:href>/calendars/john/personal/standup.ics</d:href>
+    <d:propstat>
+      <d:prop>
+        <d:getetag>"1"</d:getetag>
+        <c:calendar-data>BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:standup
+SUMMARY:Standup
+DTSTART:20260105T090000Z
+DTEND:20260105T091500Z
+RRULE:FREQ=DAILY;COUNT=5
+END:VEVENT
+END:VCALENDAR
+</c:calendar-data>
+      </d:prop>
+      <d:status>HTTP/1.1 200 OK</d:status>
+    </d:propstat>
+  </d:response>
+  <d:response>
+    <d:href>/calendars/john/personal/review.ics</d:href>
+    <d:propstat>
+      <d:prop>
+        <d:getetag>"2"</d:getetag>
+        <c:calendar-data><![CDATA[BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:review
+SUMMARY:Design review <API>
+DTSTART:20260106T120000Z
+DURATION:PT1H
+END:VEVENT
+END:VCALENDAR
+]]></c:calendar-data>
+      </d:prop>
+      <d:status>HTTP/1.1 200 OK</d:status>
+    </d:propstat>
+    <d:propstat>
+      <d:prop>
+        <x:color xmlns:x="http://apple.com/ns/ical/"/>
+      </d:prop>
+      <d:status>HTTP/1.1 404 Not Found</d:status>
+    </d:propstat>
+  </d:response>
+</d:multistatus>`
+
+func TestCalDAVGetEvents(t *testing.T) {
+	sc := &models.SearchConfig{
+		LastEditedTimeStart: time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC),
+		LastEditedTimeEnd:   time.Date(2026, 1, 7, 0, 0, 0, 0, time.UTC),
+	}
+
+	var method, depth, contentType, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "john" || password != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/calendars/john/personal/" {
+			http.NotFound(w, r)
+			return
+		}
+		method = r.Method
+		depth = r.Header.Get("Depth")
+		contentType = r.Header.Get("Content-Type")
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprint(w, caldavMultistatusBody)
+	}))
+	defer srv.Close()
+
+	repo, err := NewCalDAVRepository("calendar", &config.CalDAVConfig{
+		URL:      srv.URL + "/calendars/john/personal/",
+		Username: "john",
+		Password: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done, today, err := repo.GetEvents(context.Background(), sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if method != "REPORT" {
+		t.Errorf("method = %q, want REPORT", method)
+	}
+	if depth != "1" {
+		t.Errorf("Depth = %q, want 1", depth)
+	}
+	if !strings.HasPrefix(contentType, "application/xml") {
+		t.Errorf("Content-Type = %q, want application/xml", contentType)
+	}
+	if !strings.Contains(body, `<c:time-range start="20260105T000000Z" end="20260107T000000Z"/>`) {
+		t.Errorf("calendar-query has no search window time-range:\n%s", body)
+	}
+
+	// Instances after the window end are not expanded
+	wantDone := models.Events{
+		{Summary: "Standup", Start: time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC), End: time.Date(2026, 1, 5, 9, 15, 0, 0, time.UTC)},
+		{Summary: "Standup", Start: time.Date(2026, 1, 6, 9, 0, 0, 0, time.UTC), End: time.Date(2026, 1, 6, 9, 15, 0, 0, time.UTC)},
+		{Summary: "Design review <API>", Start: time.Date(2026, 1, 6, 12, 0, 0, 0, time.UTC), End: time.Date(2026, 1, 6, 13, 0, 0, 0, time.UTC)},
+	}
+	if !reflect.DeepEqual(done, wantDone) {
+		t.Errorf("done events = %+v, want %+v", done, wantDone)
+	}
+	if len(today) > 0 {
+		t.Errorf("today events = %+v, want none", today)
+	}
+}
+
+func TestCalDAVAuth(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.CalDAVConfig
+		want string
+	}{
+		{
+			name: "basic",
+			cfg:  config.CalDAVConfig{Username: "john", Password: "secret", Token: "ignored"},
+			want: "Basic am9objpzZWNyZXQ=",
+		},
+		{
+			name: "bearer",
+			cfg:  config.CalDAVConfig{Token: "t0ken"},
+			want: "Bearer t0ken",
+		},
+		{
+			name: "none",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var authorization string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				authorization = r.Header.Get("Authorization")
+				w.WriteHeader(http.StatusMultiStatus)
+				fmt.Fprint(w, `<d:multistatus xmlns:d="DAV:"/>`)
+			}))
+			defer srv.Close()
+
+			tt.cfg.URL = srv.URL
+			repo, err := NewCalDAVRepository("calendar", &tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			events, err := repo.QueryCalendar(context.Background(), &models.SearchConfig{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) > 0 {
+				t.Errorf("got %d events, want none", len(events))
+			}
+			if authorization != tt.want {
+				t.Errorf("Authorization = %q, want %q", authorization, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalDAVUnexpectedStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "wrong password", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	repo, err := NewCalDAVRepository("calendar", &config.CalDAVConfig{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.QueryCalendar(context.Background(), &models.SearchConfig{})
+	if err == nil || !strings.Contains(err.Error(), "401 Unauthorized: wrong password") {
+		t.Errorf("QueryCalendar() error = %v, want 401 error with the response body", err)
+	}
+}
@@ -0,0 +1,184 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nemca/taskgram/internal/helpers"
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+	"github.com/nemca/taskgram/pkg/source"
+)
+
+const (
+	gitDefaultTaskPattern = `[A-Z][A-Z0-9]+-[0-9]+`
+	// gitLogFormat is committer timestamp, ref name by which the commit was reached and subject
+	gitLogFormat = "%ct%x1f%S%x1f%s"
+)
+
+func init() {
+	source.Register("git", func(target *config.TargetsConfig) (source.Repository, error) {
+		return NewGitRepository(target.Name, &target.Git)
+	})
+}
+
+type GitRepository struct {
+	Cfg         *config.GitConfig
+	Name        string
+	TaskPattern *regexp.Regexp
+}
+
+type gitCommit struct {
+	Time    time.Time
+	Branch  string
+	Subject string
+}
+
+func NewGitRepository(name string, cfg *config.GitConfig) (*GitRepository, error) {
+	if len(cfg.AuthorEmail) < 1 {
+		return nil, fmt.Errorf("git_config.authorEmail is required")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git executable not found: %v", err)
+	}
+
+	pattern := cfg.TaskPattern
+	if len(pattern) < 1 {
+		pattern = gitDefaultTaskPattern
+	}
+	taskPattern, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("parse git_config.taskPattern: %v", err)
+	}
+
+	return &GitRepository{
+		Cfg: &config.GitConfig{
+			Repositories: cfg.Repositories,
+			AuthorEmail:  cfg.AuthorEmail,
+			MatchTasks:   cfg.MatchTasks,
+			TaskPattern:  pattern,
+			Timeout:      cfg.Timeout,
+		},
+		Name:        name,
+		TaskPattern: taskPattern,
+	}, nil
+}
+
+// Fetch implements source.Repository interface
+func (r *GitRepository) Fetch(ctx context.Context, sc *models.SearchConfig) (done models.Items, today models.Items, err error) {
+	done.Tasks, err = r.GetTasks(ctx, sc)
+	return
+}
+
+// GetTasks returns commits of the author in the search window
+// grouped by repository and branch.
+func (r *GitRepository) GetTasks(ctx context.Context, sc *models.SearchConfig) (models.Tasks, error) {
+	var tasks models.Tasks
+
+	for _, path := range r.Cfg.Repositories {
+		commits, err := r.GetCommits(ctx, path, sc)
+		if err != nil {
+			return nil, err
+		}
+
+		repoName := filepath.Base(filepath.Clean(path))
+		byBranch := make(map[string]int)
+		for _, c := range commits {
+			i, ok := byBranch[c.Branch]
+			if !ok {
+				task := models.Task{
+					Title:    fmt.Sprintf("%s (%s)", repoName, c.Branch),
+					Projects: []string{repoName},
+				}
+				if r.Cfg.MatchTasks {
+					task.Ref = r.TaskPattern.FindString(c.Branch)
+				}
+				tasks = append(tasks, task)
+				i = len(tasks) - 1
+				byBranch[c.Branch] = i
+			}
+			tasks[i].Notes = append(tasks[i].Notes, c.Subject)
+		}
+	}
+
+	return tasks, nil
+}
+
+// GetCommits returns commits of the author from local and remote branches
+// of the repository in the search window. Stash, notes and other refs are skipped.
+// Commits are in the order of git log, newest first.
+func (r *GitRepository) GetCommits(ctx context.Context, path string, sc *models.SearchConfig) ([]gitCommit, error) {
+	ctx, cancel := helpers.WithTimeout(ctx, r.Cfg.Timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "-C", path, "log",
+		"--branches", "--remotes", "--source", "--no-merges", "--fixed-strings",
+		"--author="+r.Cfg.AuthorEmail,
+		"--since="+sc.LastEditedTimeStart.Format(time.RFC3339),
+		"--until="+sc.LastEditedTimeEnd.Format(time.RFC3339),
+		"--format="+gitLogFormat,
+	)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git log in %s: %v: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var commits []gitCommit
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\x1f", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		ts, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		commit := gitCommit{
+			Time:    time.Unix(ts, 0),
+			Branch:  branchName(fields[1]),
+			Subject: fields[2],
+		}
+		if inWindow(commit.Time, sc) {
+			commits = append(commits, commit)
+		}
+	}
+
+	return commits, scanner.Err()
+}
+
+// branchName returns short branch name from the full ref name.
+func branchName(ref string) string {
+	for _, prefix := range []string{"refs/heads/", "refs/remotes/", "refs/tags/"} {
+		if strings.HasPrefix(ref, prefix) {
+			return strings.TrimPrefix(ref, prefix)
+		}
+	}
+	return ref
+}
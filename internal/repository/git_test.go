@@ -0,0 +1,134 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repository
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+// gitFixture creates a repository with commits on master and a feature branch,
+// a stash entry and a note
+func gitFixture(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git executable not found")
+	}
+
+	dir := filepath.Join(t.TempDir(), "billing")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	// Each command is a minute later, so git log order doesn't depend on timing
+	date := time.Now().Add(-30 * time.Minute)
+	git := func(args ...string) {
+		t.Helper()
+		date = date.Add(time.Minute)
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=John", "-c", "user.email=john@example.com"}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+date.Format(time.RFC3339), "GIT_COMMITTER_DATE="+date.Format(time.RFC3339))
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q")
+	git("checkout", "-q", "-b", "master")
+	write("README", "billing\n")
+	git("add", "README")
+	git("commit", "-q", "-m", "Init")
+	git("checkout", "-q", "-b", "feature/ACME-2-invoices")
+	write("invoice.go", "package billing\n")
+	git("add", "invoice.go")
+	git("commit", "-q", "-m", "Add invoices")
+	git("commit", "-q", "--allow-empty", "--author=Jane <jane@example.com>", "-m", "Not mine")
+	git("checkout", "-q", "master")
+	// Stash and notes are commits under refs/ which are not work
+	write("README", "billing WIP\n")
+	git("stash", "-q")
+	git("notes", "add", "-m", "Reviewed", "HEAD")
+
+	return dir
+}
+
+func TestGitGetTasks(t *testing.T) {
+	dir := gitFixture(t)
+
+	repo, err := NewGitRepository("git", &config.GitConfig{
+		Repositories: []string{dir},
+		AuthorEmail:  "john@example.com",
+		MatchTasks:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	tasks, err := repo.GetTasks(context.Background(), &models.SearchConfig{
+		LastEditedTimeStart: now.Add(-time.Hour),
+		LastEditedTimeEnd:   now.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := models.Tasks{
+		{Title: "billing (feature/ACME-2-invoices)", Projects: []string{"billing"}, Notes: []string{"Add invoices"}, Ref: "ACME-2"},
+		{Title: "billing (master)", Projects: []string{"billing"}, Notes: []string{"Init"}},
+	}
+	if !reflect.DeepEqual(tasks, want) {
+		t.Errorf("GetTasks() =\n%+v\nwant\n%+v", tasks, want)
+	}
+}
+
+func TestGitGetCommitsOutsideWindow(t *testing.T) {
+	dir := gitFixture(t)
+
+	repo, err := NewGitRepository("git", &config.GitConfig{Repositories: []string{dir}, AuthorEmail: "john@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now().Add(-48 * time.Hour)
+	commits, err := repo.GetCommits(context.Background(), dir, &models.SearchConfig{
+		LastEditedTimeStart: start,
+		LastEditedTimeEnd:   start.Add(24 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) > 0 {
+		t.Errorf("got %d commits, want none", len(commits))
+	}
+}
@@ -0,0 +1,309 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nemca/taskgram/internal/helpers"
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+	"github.com/nemca/taskgram/pkg/source"
+)
+
+const (
+	githubDefaultBaseURL = "https://api.github.com"
+	githubTimeLayout     = "2006-01-02T15:04:05Z"
+	githubPageSize       = 100
+	// GitHub search API returns at most 1000 results
+	githubMaxResults = 1000
+)
+
+func init() {
+	source.Register("github", func(target *config.TargetsConfig) (source.Repository, error) {
+		return NewGitHubRepository(target.Name, &target.GitHub)
+	})
+}
+
+type GitHubRepository struct {
+	Client *http.Client
+	Cfg    *config.GitHubConfig
+	Name   string
+}
+
+type githubIssue struct {
+	Number        int       `json:"number"`
+	Title         string    `json:"title"`
+	HTMLURL       string    `json:"html_url"`
+	RepositoryURL string    `json:"repository_url"`
+	State         string    `json:"state"`
+	CreatedAt     time.Time `json:"created_at"`
+	ClosedAt      time.Time `json:"closed_at"`
+	User          struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	PullRequest *struct {
+		MergedAt time.Time `json:"merged_at"`
+	} `json:"pull_request"`
+}
+
+// repository returns full name of the issue repository, e.g. "owner/repo".
+func (i githubIssue) repository() string {
+	if idx := strings.Index(i.RepositoryURL, "/repos/"); idx >= 0 {
+		return i.RepositoryURL[idx+len("/repos/"):]
+	}
+	return i.RepositoryURL
+}
+
+// githubActivity is a review or a comment of the issue
+type githubActivity struct {
+	User struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	SubmittedAt time.Time `json:"submitted_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// time returns time of the review submission or the comment creation
+func (a githubActivity) time() time.Time {
+	if !a.SubmittedAt.IsZero() {
+		return a.SubmittedAt
+	}
+	return a.CreatedAt
+}
+
+type githubSearchResponse struct {
+	TotalCount int           `json:"total_count"`
+	Items      []githubIssue `json:"items"`
+}
+
+func NewGitHubRepository(name string, cfg *config.GitHubConfig) (*GitHubRepository, error) {
+	baseURL := strings.TrimSuffix(cfg.BaseURL, "/")
+	if len(baseURL) < 1 {
+		baseURL = githubDefaultBaseURL
+	}
+
+	return &GitHubRepository{
+		Client: &http.Client{},
+		Cfg: &config.GitHubConfig{
+			BaseURL:  baseURL,
+			Token:    cfg.Token,
+			Username: cfg.Username,
+			Timeout:  cfg.Timeout,
+		},
+		Name: name,
+	}, nil
+}
+
+// Fetch implements source.Repository interface
+func (r *GitHubRepository) Fetch(ctx context.Context, sc *models.SearchConfig) (done models.Items, today models.Items, err error) {
+	done.Tasks, err = r.GetTasks(ctx, sc)
+	return
+}
+
+// GetTasks returns pull requests and issues authored, reviewed or commented
+// by the user in the search window. Tasks are sorted by repository.
+func (r *GitHubRepository) GetTasks(ctx context.Context, sc *models.SearchConfig) (models.Tasks, error) {
+	// Search username by token if it's not set explicitly
+	if len(r.Cfg.Username) < 1 {
+		login, err := r.QueryGitHubUser(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("get GitHub user: %v", err)
+		}
+		r.Cfg.Username = login
+	}
+
+	start := sc.LastEditedTimeStart.UTC().Format(githubTimeLayout)
+	window := start + ".." + sc.LastEditedTimeEnd.UTC().Format(githubTimeLayout)
+
+	// Search qualifiers match reviews and comments made at any time,
+	// so their activity path is checked for the window
+	type involvement struct {
+		qualifier string
+		note      string
+		activity  string
+		query     string
+	}
+	involvements := []involvement{
+		{qualifier: "author", note: ""},
+		{qualifier: "reviewed-by", note: "Reviewed", activity: "/repos/%s/pulls/%d/reviews?"},
+		{qualifier: "commenter", note: "Commented", activity: "/repos/%s/issues/%d/comments?", query: "since=" + url.QueryEscape(start) + "&"},
+	}
+
+	tasks := make(map[string]*models.Task)
+	issues := make(map[string]githubIssue)
+	for _, inv := range involvements {
+		query := fmt.Sprintf("%s:%s updated:%s", inv.qualifier, r.Cfg.Username, window)
+		found, err := r.SearchIssues(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, issue := range found {
+			note := inv.note
+			if inv.qualifier == "author" {
+				note = authorNote(issue, sc)
+			}
+			if len(inv.activity) > 0 {
+				active, err := r.activeInWindow(ctx, fmt.Sprintf(inv.activity, issue.repository(), issue.Number)+inv.query, sc)
+				if err != nil {
+					return nil, err
+				}
+				if !active {
+					continue
+				}
+			}
+
+			task, ok := tasks[issue.HTMLURL]
+			if !ok {
+				task = &models.Task{
+					Title:    fmt.Sprintf("%s#%d: %s", issue.repository(), issue.Number, issue.Title),
+					URL:      issue.HTMLURL,
+					Projects: []string{issue.repository()},
+				}
+				tasks[issue.HTMLURL] = task
+				issues[issue.HTMLURL] = issue
+			}
+			if len(note) > 0 && !containsString(task.Notes, note) {
+				task.Notes = append(task.Notes, note)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(tasks))
+	for k := range tasks {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := issues[keys[i]], issues[keys[j]]
+		if a.repository() != b.repository() {
+			return a.repository() < b.repository()
+		}
+		return a.Number < b.Number
+	})
+
+	result := make(models.Tasks, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, *tasks[k])
+	}
+
+	return result, nil
+}
+
+// SearchIssues returns all issues and pull requests matched by the search query.
+func (r *GitHubRepository) SearchIssues(ctx context.Context, q string) ([]githubIssue, error) {
+	var issues []githubIssue
+
+	for page := 1; len(issues) < githubMaxResults; page++ {
+		query := url.Values{}
+		query.Set("q", q)
+		query.Set("sort", "updated")
+		query.Set("per_page", strconv.Itoa(githubPageSize))
+		query.Set("page", strconv.Itoa(page))
+
+		var resp githubSearchResponse
+		if err := r.get(ctx, "/search/issues?"+query.Encode(), &resp); err != nil {
+			return nil, fmt.Errorf("search GitHub issues: %v", err)
+		}
+
+		issues = append(issues, resp.Items...)
+		if len(resp.Items) < githubPageSize || len(issues) >= resp.TotalCount {
+			break
+		}
+	}
+
+	return issues, nil
+}
+
+// activeInWindow reports whether the user reviewed or commented in the search window.
+// The path is a list of reviews or comments ending with ? or &.
+func (r *GitHubRepository) activeInWindow(ctx context.Context, path string, sc *models.SearchConfig) (bool, error) {
+	for page := 1; ; page++ {
+		var activities []githubActivity
+		if err := r.get(ctx, fmt.Sprintf("%sper_page=%d&page=%d", path, githubPageSize, page), &activities); err != nil {
+			return false, fmt.Errorf("get GitHub activity: %v", err)
+		}
+
+		for _, a := range activities {
+			if strings.EqualFold(a.User.Login, r.Cfg.Username) && inWindow(a.time(), sc) {
+				return true, nil
+			}
+		}
+		if len(activities) < githubPageSize {
+			return false, nil
+		}
+	}
+}
+
+// QueryGitHubUser returns login of the token owner.
+func (r *GitHubRepository) QueryGitHubUser(ctx context.Context) (string, error) {
+	var user struct {
+		Login string `json:"login"`
+	}
+	err := r.get(ctx, "/user", &user)
+	return user.Login, err
+}
+
+func (r *GitHubRepository) get(ctx context.Context, path string, v interface{}) error {
+	ctx, cancel := helpers.WithTimeout(ctx, r.Cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.Cfg.BaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if len(r.Cfg.Token) > 0 {
+		req.Header.Set("Authorization", "token "+r.Cfg.Token)
+	}
+
+	return doJSON(r.Client, req, v)
+}
+
+// authorNote describes what happened in the search window with the issue authored by user.
+func authorNote(issue githubIssue, sc *models.SearchConfig) string {
+	kind := "issue"
+	if issue.PullRequest != nil {
+		kind = "pull request"
+		if inWindow(issue.PullRequest.MergedAt, sc) {
+			return "Merged " + kind
+		}
+	}
+	switch {
+	case inWindow(issue.CreatedAt, sc):
+		return "Opened " + kind
+	case inWindow(issue.ClosedAt, sc):
+		return "Closed " + kind
+	}
+	return "Updated " + kind
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+func TestGitHubGetTasks(t *testing.T) {
+	now := time.Now().UTC()
+	sc := &models.SearchConfig{LastEditedTimeStart: now.Add(-24 * time.Hour), LastEditedTimeEnd: now}
+	inside := now.Add(-time.Hour).Format(githubTimeLayout)
+	outside := now.Add(-72 * time.Hour).Format(githubTimeLayout)
+
+	issue := func(repo string, number int, title, extra string) string {
+		return fmt.Sprintf(`{"number":%d,"title":%q,"html_url":"https://github.com/%s/pull/%d",
+			"repository_url":"https://api.github.com/repos/%s","created_at":%q%s}`,
+			number, title, repo, number, repo, outside, extra)
+	}
+
+	var queries []string
+	var commentsSince string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "token secret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if got := r.Header.Get("Accept"); got != "application/vnd.github.v3+json" {
+			t.Errorf("Accept = %q", got)
+		}
+
+		switch r.URL.Path {
+		case "/user":
+			fmt.Fprint(w, `{"login":"octocat"}`)
+		case "/search/issues":
+			q := r.URL.Query().Get("q")
+			queries = append(queries, q)
+			var items []string
+			switch {
+			case strings.HasPrefix(q, "author:"):
+				items = append(items, issue("acme/api", 2, "Add billing", fmt.Sprintf(`,"pull_request":{"merged_at":%q}`, inside)))
+			case strings.HasPrefix(q, "reviewed-by:"):
+				items = append(items,
+					issue("acme/web", 7, "Fresh review", `,"pull_request":{}`),
+					issue("acme/web", 5, "Old review", `,"pull_request":{}`))
+			case strings.HasPrefix(q, "commenter:"):
+				items = append(items, issue("acme/api", 1, "Crash on start", ""))
+			}
+			fmt.Fprintf(w, `{"total_count":%d,"items":[%s]}`, len(items), strings.Join(items, ","))
+		case "/repos/acme/web/pulls/7/reviews":
+			fmt.Fprintf(w, `[{"user":{"login":"someone"},"submitted_at":%q},{"user":{"login":"OctoCat"},"submitted_at":%q}]`, inside, inside)
+		case "/repos/acme/web/pulls/5/reviews":
+			// Reviewed long ago, the pull request is updated by others
+			fmt.Fprintf(w, `[{"user":{"login":"octocat"},"submitted_at":%q}]`, outside)
+		case "/repos/acme/api/issues/1/comments":
+			commentsSince = r.URL.Query().Get("since")
+			fmt.Fprintf(w, `[{"user":{"login":"octocat"},"created_at":%q}]`, inside)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	repo, err := NewGitHubRepository("github", &config.GitHubConfig{BaseURL: srv.URL + "/", Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := repo.GetTasks(context.Background(), sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := models.Tasks{
+		{Title: "acme/api#1: Crash on start", URL: "https://github.com/acme/api/pull/1", Projects: []string{"acme/api"}, Notes: []string{"Commented"}},
+		{Title: "acme/api#2: Add billing", URL: "https://github.com/acme/api/pull/2", Projects: []string{"acme/api"}, Notes: []string{"Merged pull request"}},
+		{Title: "acme/web#7: Fresh review", URL: "https://github.com/acme/web/pull/7", Projects: []string{"acme/web"}, Notes: []string{"Reviewed"}},
+	}
+	if !reflect.DeepEqual(tasks, want) {
+		t.Errorf("GetTasks() = %+v, want %+v", tasks, want)
+	}
+
+	window := sc.LastEditedTimeStart.Format(githubTimeLayout) + ".." + sc.LastEditedTimeEnd.Format(githubTimeLayout)
+	wantQueries := []string{
+		"author:octocat updated:" + window,
+		"reviewed-by:octocat updated:" + window,
+		"commenter:octocat updated:" + window,
+	}
+	if !reflect.DeepEqual(queries, wantQueries) {
+		t.Errorf("queries = %q, want %q", queries, wantQueries)
+	}
+	if commentsSince != sc.LastEditedTimeStart.Format(githubTimeLayout) {
+		t.Errorf("comments since = %q", commentsSince)
+	}
+}
+
+func TestGitHubUserIsResolvedInFetch(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	repo, err := NewGitHubRepository("github", &config.GitHubConfig{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("constructor must not call the API: %v", err)
+	}
+	if requests > 0 {
+		t.Errorf("constructor sent %d requests", requests)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := repo.Fetch(ctx, &models.SearchConfig{}); err == nil {
+		t.Error("Fetch with cancelled context succeeded")
+	}
+}
@@ -0,0 +1,238 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nemca/taskgram/internal/helpers"
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+	"github.com/nemca/taskgram/pkg/source"
+)
+
+const (
+	gitlabDefaultBaseURL = "https://gitlab.com"
+	gitlabDateLayout     = "2006-01-02"
+	gitlabPageSize       = 100
+)
+
+const (
+	gitlabTargetMergeRequest = "MergeRequest"
+	gitlabTargetIssue        = "Issue"
+)
+
+func init() {
+	source.Register("gitlab", func(target *config.TargetsConfig) (source.Repository, error) {
+		return NewGitLabRepository(target.Name, &target.GitLab)
+	})
+}
+
+type GitLabRepository struct {
+	Client *http.Client
+	Cfg    *config.GitLabConfig
+	Name   string
+}
+
+type gitlabEvent struct {
+	ProjectID   int       `json:"project_id"`
+	ActionName  string    `json:"action_name"`
+	TargetType  string    `json:"target_type"`
+	TargetIID   int       `json:"target_iid"`
+	TargetTitle string    `json:"target_title"`
+	CreatedAt   time.Time `json:"created_at"`
+	Note        *struct {
+		Body         string `json:"body"`
+		NoteableType string `json:"noteable_type"`
+		NoteableIID  int    `json:"noteable_iid"`
+	} `json:"note"`
+}
+
+type gitlabProject struct {
+	PathWithNamespace string `json:"path_with_namespace"`
+	WebURL            string `json:"web_url"`
+}
+
+func NewGitLabRepository(name string, cfg *config.GitLabConfig) (*GitLabRepository, error) {
+	baseURL := strings.TrimSuffix(cfg.BaseURL, "/")
+	if len(baseURL) < 1 {
+		baseURL = gitlabDefaultBaseURL
+	}
+
+	return &GitLabRepository{
+		Client: &http.Client{},
+		Cfg: &config.GitLabConfig{
+			BaseURL: baseURL,
+			Token:   cfg.Token,
+			Timeout: cfg.Timeout,
+		},
+		Name: name,
+	}, nil
+}
+
+// Fetch implements source.Repository interface
+func (r *GitLabRepository) Fetch(ctx context.Context, sc *models.SearchConfig) (done models.Items, today models.Items, err error) {
+	done.Tasks, err = r.GetTasks(ctx, sc)
+	return
+}
+
+// GetTasks returns merge requests and issues the user opened, merged, approved
+// or commented in the search window.
+func (r *GitLabRepository) GetTasks(ctx context.Context, sc *models.SearchConfig) (models.Tasks, error) {
+	events, err := r.GetEvents(ctx, sc)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks models.Tasks
+	byTarget := make(map[string]int)
+	projects := make(map[int]gitlabProject)
+	for _, event := range events {
+		targetType, iid, note := gitlabEventNote(event)
+		if len(note) < 1 {
+			continue
+		}
+
+		key := fmt.Sprintf("%d/%s/%d", event.ProjectID, targetType, iid)
+		i, ok := byTarget[key]
+		if !ok {
+			project, ok := projects[event.ProjectID]
+			if !ok {
+				project, err = r.GetProject(ctx, event.ProjectID)
+				if err != nil {
+					return nil, err
+				}
+				projects[event.ProjectID] = project
+			}
+
+			path := "issues"
+			if targetType == gitlabTargetMergeRequest {
+				path = "merge_requests"
+			}
+			tasks = append(tasks, models.Task{
+				Title:    event.TargetTitle,
+				URL:      fmt.Sprintf("%s/-/%s/%d", project.WebURL, path, iid),
+				Projects: []string{project.PathWithNamespace},
+			})
+			i = len(tasks) - 1
+			byTarget[key] = i
+		}
+		if !containsString(tasks[i].Notes, note) {
+			tasks[i].Notes = append(tasks[i].Notes, note)
+		}
+	}
+
+	return tasks, nil
+}
+
+// GetEvents returns events of the current user in the search window, oldest first.
+func (r *GitLabRepository) GetEvents(ctx context.Context, sc *models.SearchConfig) ([]gitlabEvent, error) {
+	var events []gitlabEvent
+
+	// after and before are exclusive dates, events are filtered by time later
+	after := sc.LastEditedTimeStart.AddDate(0, 0, -1).UTC().Format(gitlabDateLayout)
+	before := sc.LastEditedTimeEnd.AddDate(0, 0, 1).UTC().Format(gitlabDateLayout)
+	for page := "1"; len(page) > 0; {
+		query := url.Values{}
+		query.Set("after", after)
+		query.Set("before", before)
+		query.Set("sort", "asc")
+		query.Set("per_page", strconv.Itoa(gitlabPageSize))
+		query.Set("page", page)
+
+		var resp []gitlabEvent
+		header, err := r.get(ctx, "/api/v4/events?"+query.Encode(), &resp)
+		if err != nil {
+			return nil, fmt.Errorf("get GitLab events: %v", err)
+		}
+		for _, event := range resp {
+			if inWindow(event.CreatedAt, sc) {
+				events = append(events, event)
+			}
+		}
+		// X-Next-Page is empty on the last page
+		page = header.Get("X-Next-Page")
+	}
+
+	return events, nil
+}
+
+// GetProject returns project by ID.
+func (r *GitLabRepository) GetProject(ctx context.Context, id int) (gitlabProject, error) {
+	var project gitlabProject
+	if _, err := r.get(ctx, fmt.Sprintf("/api/v4/projects/%d", id), &project); err != nil {
+		return project, fmt.Errorf("get GitLab project %d: %v", id, err)
+	}
+	return project, nil
+}
+
+func (r *GitLabRepository) get(ctx context.Context, path string, v interface{}) (http.Header, error) {
+	ctx, cancel := helpers.WithTimeout(ctx, r.Cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.Cfg.BaseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("PRIVATE-TOKEN", r.Cfg.Token)
+
+	return doJSONHeader(r.Client, req, v)
+}
+
+// gitlabEventNote returns the merge request or issue the event is about and
+// a note describing the event. Empty note means the event should be skipped,
+// e.g. pushes, which are reported by git targets.
+func gitlabEventNote(event gitlabEvent) (targetType string, iid int, note string) {
+	if event.Note != nil {
+		switch event.Note.NoteableType {
+		case gitlabTargetMergeRequest, gitlabTargetIssue:
+			return event.Note.NoteableType, event.Note.NoteableIID, "Commented: " + firstLine(event.Note.Body)
+		}
+		return "", 0, ""
+	}
+
+	var kind string
+	switch event.TargetType {
+	case gitlabTargetMergeRequest:
+		kind = "merge request"
+	case gitlabTargetIssue:
+		kind = "issue"
+	default:
+		return "", 0, ""
+	}
+
+	switch event.ActionName {
+	case "opened":
+		note = "Opened " + kind
+	case "accepted":
+		note = "Merged " + kind
+	case "approved":
+		note = "Approved " + kind
+	case "closed":
+		note = "Closed " + kind
+	case "reopened":
+		note = "Reopened " + kind
+	}
+
+	return event.TargetType, event.TargetIID, note
+}
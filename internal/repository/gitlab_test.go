@@ -0,0 +1,165 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+func TestGitLabGetTasks(t *testing.T) {
+	now := time.Now().UTC()
+	sc := &models.SearchConfig{LastEditedTimeStart: now.Add(-24 * time.Hour), LastEditedTimeEnd: now}
+	at := func(d time.Duration) string { return now.Add(-d).Format(time.RFC3339) }
+
+	pages := map[string]string{
+		"1": fmt.Sprintf(`[
+			{"project_id":7,"action_name":"opened","target_type":"MergeRequest","target_iid":3,"target_title":"Billing","created_at":%q},
+			{"project_id":7,"action_name":"pushed to","target_type":null,"push_data":{"commit_count":2,"ref":"billing"},"created_at":%q},
+			{"project_id":7,"action_name":"commented on","target_type":"Note","target_title":"Billing","created_at":%q,
+				"note":{"body":"LGTM\nwith a nit","noteable_type":"MergeRequest","noteable_iid":3}},
+			{"project_id":9,"action_name":"commented on","target_type":"DiffNote","target_title":"Crash","created_at":%q,
+				"note":{"body":"Repro steps","noteable_type":"Issue","noteable_iid":5}},
+			{"project_id":7,"action_name":"commented on","target_type":"Note","target_title":"Snippet","created_at":%q,
+				"note":{"body":"Nice","noteable_type":"Snippet","noteable_iid":1}},
+			{"project_id":7,"action_name":"opened","target_type":"Issue","target_iid":4,"target_title":"Too old","created_at":%q}
+		]`, at(20*time.Hour), at(19*time.Hour), at(18*time.Hour), at(17*time.Hour), at(16*time.Hour), at(30*time.Hour)),
+		"2": fmt.Sprintf(`[
+			{"project_id":7,"action_name":"approved","target_type":"MergeRequest","target_iid":3,"target_title":"Billing","created_at":%q},
+			{"project_id":7,"action_name":"accepted","target_type":"MergeRequest","target_iid":3,"target_title":"Billing","created_at":%q},
+			{"project_id":7,"action_name":"commented on","target_type":"Note","target_title":"Billing","created_at":%q,
+				"note":{"body":"LGTM","noteable_type":"MergeRequest","noteable_iid":3}},
+			{"project_id":7,"action_name":"closed","target_type":"Issue","target_iid":3,"target_title":"Flaky test","created_at":%q}
+		]`, at(5*time.Hour), at(4*time.Hour), at(3*time.Hour), at(2*time.Hour)),
+	}
+
+	var eventQueries []string
+	projectRequests := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "glpat-secret" {
+			http.Error(w, `{"message":"401 Unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/api/v4/events":
+			eventQueries = append(eventQueries, r.URL.RawQuery)
+			page := r.URL.Query().Get("page")
+			body, ok := pages[page]
+			if !ok {
+				http.Error(w, "unknown page", http.StatusBadRequest)
+				return
+			}
+			next := ""
+			if page == "1" {
+				next = "2"
+			}
+			w.Header().Set("X-Next-Page", next)
+			fmt.Fprint(w, body)
+		case strings.HasPrefix(r.URL.Path, "/api/v4/projects/"):
+			id := strings.TrimPrefix(r.URL.Path, "/api/v4/projects/")
+			projectRequests[id]++
+			paths := map[string]string{"7": "acme/billing", "9": "acme/app"}
+			fmt.Fprintf(w, `{"path_with_namespace":%q,"web_url":"https://gitlab.example.com/%s"}`, paths[id], paths[id])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	repo, err := NewGitLabRepository("gitlab", &config.GitLabConfig{BaseURL: srv.URL + "/", Token: "glpat-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasks, err := repo.GetTasks(context.Background(), sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := models.Tasks{
+		{
+			Title:    "Billing",
+			URL:      "https://gitlab.example.com/acme/billing/-/merge_requests/3",
+			Projects: []string{"acme/billing"},
+			Notes:    []string{"Opened merge request", "Commented: LGTM", "Approved merge request", "Merged merge request"},
+		},
+		{
+			Title:    "Crash",
+			URL:      "https://gitlab.example.com/acme/app/-/issues/5",
+			Projects: []string{"acme/app"},
+			Notes:    []string{"Commented: Repro steps"},
+		},
+		{
+			Title:    "Flaky test",
+			URL:      "https://gitlab.example.com/acme/billing/-/issues/3",
+			Projects: []string{"acme/billing"},
+			Notes:    []string{"Closed issue"},
+		},
+	}
+	if !reflect.DeepEqual(tasks, want) {
+		t.Errorf("GetTasks() =\n%+v\nwant\n%+v", tasks, want)
+	}
+
+	if len(eventQueries) != 2 {
+		t.Fatalf("got %d events requests, want 2 pages", len(eventQueries))
+	}
+	query := mustQuery(t, eventQueries[0])
+	wantAfter := sc.LastEditedTimeStart.AddDate(0, 0, -1).Format(gitlabDateLayout)
+	wantBefore := sc.LastEditedTimeEnd.AddDate(0, 0, 1).Format(gitlabDateLayout)
+	if query.Get("after") != wantAfter || query.Get("before") != wantBefore || query.Get("sort") != "asc" || query.Get("per_page") != "100" {
+		t.Errorf("events query = %q, want after=%s before=%s sort=asc per_page=100", eventQueries[0], wantAfter, wantBefore)
+	}
+	// Projects are requested once
+	if !reflect.DeepEqual(projectRequests, map[string]int{"7": 1, "9": 1}) {
+		t.Errorf("project requests = %v", projectRequests)
+	}
+}
+
+func TestGitLabEventNote(t *testing.T) {
+	tests := []struct {
+		action, target string
+		want           string
+	}{
+		{action: "opened", target: "MergeRequest", want: "Opened merge request"},
+		{action: "accepted", target: "MergeRequest", want: "Merged merge request"},
+		{action: "approved", target: "MergeRequest", want: "Approved merge request"},
+		{action: "closed", target: "Issue", want: "Closed issue"},
+		{action: "reopened", target: "Issue", want: "Reopened issue"},
+		{action: "pushed to", target: ""},
+		{action: "pushed new", target: ""},
+		{action: "joined", target: ""},
+		{action: "updated", target: "WikiPage::Meta"},
+		{action: "updated", target: "MergeRequest"},
+	}
+
+	for _, tt := range tests {
+		_, _, note := gitlabEventNote(gitlabEvent{ActionName: tt.action, TargetType: tt.target, TargetIID: 1})
+		if note != tt.want {
+			t.Errorf("gitlabEventNote(%q, %q) = %q, want %q", tt.action, tt.target, note, tt.want)
+		}
+	}
+}
@@ -26,14 +26,22 @@ import (
 	"os"
 	"time"
 
-	"github.com/nemca/taskgram/internal/config"
-	"github.com/nemca/taskgram/internal/models"
+	"github.com/nemca/taskgram/internal/helpers"
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+	"github.com/nemca/taskgram/pkg/source"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/calendar/v3"
 	"google.golang.org/api/option"
 )
 
+func init() {
+	source.Register("google_calendar", func(target *config.TargetsConfig) (source.Repository, error) {
+		return NewCalendarRepository(target.Name, &target.GoogleCalendar)
+	})
+}
+
 type CalendarRepository struct {
 	Name    string
 	Service *calendar.Service
@@ -68,28 +76,46 @@ func NewCalendarRepository(name string, cfg *config.GoogleCalendarConfig) (*Cale
 	}, nil
 }
 
-func (r *CalendarRepository) GetEvents(cfg *config.Config, sc *models.SearchConfig) (doneEvents models.Events, todayEvents models.Events, err error) {
+// Fetch implements source.Repository interface
+func (r *CalendarRepository) Fetch(ctx context.Context, sc *models.SearchConfig) (done models.Items, today models.Items, err error) {
+	done.Events, today.Events, err = r.GetEvents(ctx, sc)
+	return
+}
+
+func (r *CalendarRepository) GetEvents(ctx context.Context, sc *models.SearchConfig) (doneEvents models.Events, todayEvents models.Events, err error) {
 	timeMin := sc.LastEditedTimeStart.Format(time.RFC3339)
 	timeMax := sc.LastEditedTimeEnd.Format(time.RFC3339)
 
-	events, err := r.Service.Events.List("mikhail.b@p2p.org").ShowDeleted(false).
-		SingleEvents(true).TimeMin(timeMin).TimeMax(timeMax).MaxResults(10).OrderBy("startTime").Do()
-	if err != nil {
-		return nil, nil, fmt.Errorf("Unable to retrieve next ten of the user's events: %v", err)
+	calendarID := r.Cfg.CalendarID
+	if len(calendarID) < 1 {
+		calendarID = "primary"
 	}
 
-	if len(events.Items) > 0 {
-		for _, item := range events.Items {
-			date, _ := time.Parse(time.RFC3339, item.Start.DateTime)
-			event := models.Event{
-				Summary: item.Summary,
+	ctx, cancel := helpers.WithTimeout(ctx, r.Cfg.Timeout)
+	defer cancel()
+
+	// Long windows, e.g. a sprint summary, have many events, so read all pages
+	err = r.Service.Events.List(calendarID).ShowDeleted(false).
+		SingleEvents(true).TimeMin(timeMin).TimeMax(timeMax).MaxResults(250).OrderBy("startTime").
+		Pages(ctx, func(events *calendar.Events) error {
+			for _, item := range events.Items {
+				date, _ := time.Parse(time.RFC3339, item.Start.DateTime)
+				end, _ := time.Parse(time.RFC3339, item.End.DateTime)
+				event := models.Event{
+					Summary: item.Summary,
+					Start:   date,
+					End:     end,
+				}
+				if date.Before(time.Now()) {
+					doneEvents = append(doneEvents, event)
+				} else {
+					todayEvents = append(todayEvents, event)
+				}
 			}
-			if date.Before(time.Now()) {
-				doneEvents = append(doneEvents, event)
-			} else {
-				todayEvents = append(todayEvents, event)
-			}
-		}
+			return nil
+		})
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to retrieve the user's events: %v", err)
 	}
 
 	return
@@ -112,7 +138,7 @@ func getClient(config *oauth2.Config, tokenPath string) *http.Client {
 // Request a token from the web, then returns the retrieved token.
 func getTokenFromWeb(config *oauth2.Config) *oauth2.Token {
 	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-	fmt.Printf("Go to the following link in your browser then type the "+
+	fmt.Fprintf(os.Stderr, "Go to the following link in your browser then type the "+
 		"authorization code: \n%v\n", authURL)
 
 	var authCode string
@@ -141,7 +167,7 @@ func tokenFromFile(file string) (*oauth2.Token, error) {
 
 // Saves a token to a file path.
 func saveToken(path string, token *oauth2.Token) {
-	fmt.Printf("Saving credential file to: %s\n", path)
+	fmt.Fprintf(os.Stderr, "Saving credential file to: %s\n", path)
 	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		log.Fatalf("Unable to cache oauth token: %v", err)
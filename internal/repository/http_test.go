@@ -17,10 +17,16 @@ limitations under the License.
 package repository
 
 import (
-	"github.com/nemca/taskgram/internal/config"
-	"github.com/nemca/taskgram/internal/models"
+	"net/url"
+	"testing"
 )
 
-type Repository interface {
-	GetTasks(cfg config.Config, sc models.SearchConfig)
+// mustQuery parses the raw query of a request to a fake server
+func mustQuery(t *testing.T, rawQuery string) url.Values {
+	t.Helper()
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		t.Fatalf("parse query %q: %v", rawQuery, err)
+	}
+	return values
 }
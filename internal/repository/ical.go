@@ -0,0 +1,147 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nemca/taskgram/internal/helpers"
+	"github.com/nemca/taskgram/internal/ical"
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+	"github.com/nemca/taskgram/pkg/source"
+)
+
+func init() {
+	source.Register("ical", func(target *config.TargetsConfig) (source.Repository, error) {
+		return NewICalRepository(target.Name, &target.ICal)
+	})
+}
+
+type ICalRepository struct {
+	Client *http.Client
+	Cfg    *config.ICalConfig
+	Name   string
+}
+
+func NewICalRepository(name string, cfg *config.ICalConfig) (*ICalRepository, error) {
+	if len(cfg.Files) < 1 && len(cfg.URL) < 1 {
+		return nil, fmt.Errorf("ical_config.files or ical_config.url is required")
+	}
+
+	return &ICalRepository{
+		Client: &http.Client{},
+		Cfg: &config.ICalConfig{
+			Files:   cfg.Files,
+			URL:     cfg.URL,
+			Timeout: cfg.Timeout,
+		},
+		Name: name,
+	}, nil
+}
+
+// Fetch implements source.Repository interface
+func (r *ICalRepository) Fetch(ctx context.Context, sc *models.SearchConfig) (done models.Items, today models.Items, err error) {
+	done.Events, today.Events, err = r.GetEvents(ctx, sc)
+	return
+}
+
+// GetEvents returns events from the files and the feed in the search window
+// including instances of recurring events.
+func (r *ICalRepository) GetEvents(ctx context.Context, sc *models.SearchConfig) (doneEvents models.Events, todayEvents models.Events, err error) {
+	var events []ical.Event
+
+	for _, path := range r.Cfg.Files {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		parsed, err := ical.Parse(f)
+		f.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("parse %s: %v", path, err)
+		}
+		events = append(events, parsed...)
+	}
+
+	if len(r.Cfg.URL) > 0 {
+		parsed, err := r.getFeed(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		events = append(events, parsed...)
+	}
+
+	doneEvents, todayEvents = splitEvents(ical.Expand(events, sc.LastEditedTimeStart, sc.LastEditedTimeEnd))
+	return doneEvents, todayEvents, nil
+}
+
+func (r *ICalRepository) getFeed(ctx context.Context) ([]ical.Event, error) {
+	ctx, cancel := helpers.WithTimeout(ctx, r.Cfg.Timeout)
+	defer cancel()
+
+	// webcal:// is a common scheme for calendar feeds served over https
+	feedURL := r.Cfg.URL
+	if strings.HasPrefix(feedURL, "webcal://") {
+		feedURL = "https://" + strings.TrimPrefix(feedURL, "webcal://")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/calendar")
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get calendar feed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get calendar feed: unexpected status %s", resp.Status)
+	}
+
+	events, err := ical.Parse(io.LimitReader(resp.Body, 64<<20))
+	if err != nil {
+		return nil, fmt.Errorf("parse calendar feed: %v", err)
+	}
+	return events, nil
+}
+
+// splitEvents splits events to already started and upcoming ones.
+func splitEvents(events []ical.Event) (doneEvents models.Events, todayEvents models.Events) {
+	now := time.Now()
+	for _, e := range events {
+		event := models.Event{
+			Summary: e.Summary,
+			Start:   e.Start,
+			End:     e.End,
+		}
+		if e.Start.Before(now) {
+			doneEvents = append(doneEvents, event)
+		} else {
+			todayEvents = append(todayEvents, event)
+		}
+	}
+	return
+}
@@ -0,0 +1,467 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nemca/taskgram/internal/helpers"
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+	"github.com/nemca/taskgram/pkg/source"
+)
+
+const (
+	jiraTimeLayout   = "2006-01-02T15:04:05.000-0700"
+	jiraPageSize     = 50
+	jiraSearchFields = "summary,project,comment,worklog"
+)
+
+// Jira deployments
+const (
+	jiraDeploymentCloud  = "cloud"
+	jiraDeploymentServer = "server"
+)
+
+func init() {
+	source.Register("jira", func(target *config.TargetsConfig) (source.Repository, error) {
+		return NewJiraRepository(target.Name, &target.Jira)
+	})
+}
+
+type JiraRepository struct {
+	Client  *http.Client
+	BaseURL *url.URL
+	Cfg     *config.JiraConfig
+	Name    string
+}
+
+// jiraTime is a timestamp in Jira REST API format
+type jiraTime struct {
+	time.Time
+}
+
+func (t *jiraTime) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		return nil
+	}
+	parsed, err := time.Parse(jiraTimeLayout, s)
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
+
+type jiraUser struct {
+	AccountID   string `json:"accountId"`
+	Name        string `json:"name"`
+	Key         string `json:"key"`
+	DisplayName string `json:"displayName"`
+}
+
+// is reports whether u is the same user as other.
+// Jira Cloud identifies users by accountId, Jira Server by name.
+func (u jiraUser) is(other jiraUser) bool {
+	if len(u.AccountID) > 0 {
+		return u.AccountID == other.AccountID
+	}
+	return len(u.Name) > 0 && u.Name == other.Name
+}
+
+// jiraText is a plain text field, in REST API v3 it's Atlassian Document Format
+type jiraText string
+
+func (t *jiraText) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*t = jiraText(s)
+		return nil
+	}
+
+	var doc jiraDocNode
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	var buf strings.Builder
+	doc.writeText(&buf)
+	*t = jiraText(buf.String())
+	return nil
+}
+
+// jiraDocNode is a node of Atlassian Document Format
+type jiraDocNode struct {
+	Type    string        `json:"type"`
+	Text    string        `json:"text"`
+	Content []jiraDocNode `json:"content"`
+}
+
+// writeText writes text of the node, block nodes end with a new line
+func (n jiraDocNode) writeText(buf *strings.Builder) {
+	switch n.Type {
+	case "text":
+		buf.WriteString(n.Text)
+	case "hardBreak":
+		buf.WriteString("\n")
+	}
+	for _, child := range n.Content {
+		child.writeText(buf)
+	}
+	switch n.Type {
+	case "paragraph", "heading", "codeBlock", "blockquote", "listItem":
+		buf.WriteString("\n")
+	}
+}
+
+type jiraComment struct {
+	Author  jiraUser `json:"author"`
+	Body    jiraText `json:"body"`
+	Created jiraTime `json:"created"`
+	Updated jiraTime `json:"updated"`
+}
+
+type jiraWorklog struct {
+	Author    jiraUser `json:"author"`
+	Comment   jiraText `json:"comment"`
+	TimeSpent string   `json:"timeSpent"`
+	Started   jiraTime `json:"started"`
+}
+
+// jiraComments is a page of comments, search results have only the first page
+type jiraComments struct {
+	Total    int           `json:"total"`
+	Comments []jiraComment `json:"comments"`
+}
+
+// jiraWorklogs is a page of worklogs, search results have only the first page
+type jiraWorklogs struct {
+	Total    int           `json:"total"`
+	Worklogs []jiraWorklog `json:"worklogs"`
+}
+
+type jiraIssue struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Summary string `json:"summary"`
+		Project struct {
+			Key string `json:"key"`
+		} `json:"project"`
+		Comment jiraComments `json:"comment"`
+		Worklog jiraWorklogs `json:"worklog"`
+	} `json:"fields"`
+}
+
+type jiraSearchResponse struct {
+	StartAt    int         `json:"startAt"`
+	MaxResults int         `json:"maxResults"`
+	Total      int         `json:"total"`
+	Issues     []jiraIssue `json:"issues"`
+}
+
+// jiraSearchJQLResponse is a page of Jira Cloud enhanced search
+type jiraSearchJQLResponse struct {
+	Issues        []jiraIssue `json:"issues"`
+	NextPageToken string      `json:"nextPageToken"`
+	IsLast        bool        `json:"isLast"`
+}
+
+func NewJiraRepository(name string, cfg *config.JiraConfig) (*JiraRepository, error) {
+	if len(cfg.BaseURL) < 1 {
+		return nil, fmt.Errorf("jira_config.baseURL is required")
+	}
+	baseURL, err := url.Parse(strings.TrimSuffix(cfg.BaseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("parse jira_config.baseURL: %v", err)
+	}
+
+	deployment := strings.ToLower(cfg.Deployment)
+	switch deployment {
+	case jiraDeploymentCloud, jiraDeploymentServer:
+	case "":
+		deployment = jiraDeploymentServer
+		if strings.HasSuffix(strings.ToLower(baseURL.Hostname()), ".atlassian.net") {
+			deployment = jiraDeploymentCloud
+		}
+	default:
+		return nil, fmt.Errorf("unknown jira_config.deployment %q", cfg.Deployment)
+	}
+
+	return &JiraRepository{
+		Client:  &http.Client{},
+		BaseURL: baseURL,
+		Cfg: &config.JiraConfig{
+			BaseURL:    baseURL.String(),
+			Username:   cfg.Username,
+			APIToken:   cfg.APIToken,
+			Timeout:    cfg.Timeout,
+			JQL:        cfg.JQL,
+			Deployment: deployment,
+		},
+		Name: name,
+	}, nil
+}
+
+// Fetch implements source.Repository interface
+func (r *JiraRepository) Fetch(ctx context.Context, sc *models.SearchConfig) (done models.Items, today models.Items, err error) {
+	done.Tasks, err = r.GetTasks(ctx, sc)
+	return
+}
+
+// GetTasks returns issues assigned to the user and updated in the search window.
+// Comments and worklogs of the user become notes of the tasks.
+func (r *JiraRepository) GetTasks(ctx context.Context, sc *models.SearchConfig) (models.Tasks, error) {
+	myself, err := r.QueryJiraUser(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get current jira user: %v", err)
+	}
+
+	issues, err := r.SearchIssues(ctx, r.searchJQL(sc))
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks models.Tasks
+	for _, issue := range issues {
+		// Long-running issues have more comments and worklogs than the search returns
+		if c := &issue.Fields.Comment; c.Total > len(c.Comments) {
+			if c.Comments, err = r.IssueComments(ctx, issue.Key); err != nil {
+				return nil, err
+			}
+		}
+		if w := &issue.Fields.Worklog; w.Total > len(w.Worklogs) {
+			if w.Worklogs, err = r.IssueWorklogs(ctx, issue.Key, sc); err != nil {
+				return nil, err
+			}
+		}
+
+		task := models.Task{
+			Title: issue.Key,
+			URL:   r.BaseURL.String() + "/browse/" + issue.Key,
+			Notes: issueNotes(issue, myself, sc),
+		}
+		if len(issue.Fields.Project.Key) > 0 {
+			task.Projects = []string{issue.Fields.Project.Key}
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, nil
+}
+
+// searchJQL builds JQL for issues assigned to the current user and updated in the search window.
+// Relative dates are used, because absolute dates in JQL are in the user's profile time zone.
+func (r *JiraRepository) searchJQL(sc *models.SearchConfig) string {
+	clauses := []string{
+		"assignee = currentUser()",
+		fmt.Sprintf("updated >= -%dm", minutesSince(sc.LastEditedTimeStart)),
+	}
+	if end := minutesSince(sc.LastEditedTimeEnd); end > 0 {
+		clauses = append(clauses, fmt.Sprintf("updated <= -%dm", end))
+	}
+	if len(r.Cfg.JQL) > 0 {
+		clauses = append(clauses, "("+r.Cfg.JQL+")")
+	}
+
+	return strings.Join(clauses, " AND ") + " ORDER BY updated DESC"
+}
+
+// SearchIssues returns all issues matched by JQL.
+// Jira Cloud is searched with enhanced search API paged by token,
+// Jira Server with search API paged by offset.
+func (r *JiraRepository) SearchIssues(ctx context.Context, jql string) ([]jiraIssue, error) {
+	if r.Cfg.Deployment == jiraDeploymentCloud {
+		return r.searchIssuesCloud(ctx, jql)
+	}
+
+	var issues []jiraIssue
+
+	for startAt, total := 0, 1; startAt < total; {
+		query := url.Values{}
+		query.Set("jql", jql)
+		query.Set("fields", jiraSearchFields)
+		query.Set("startAt", strconv.Itoa(startAt))
+		query.Set("maxResults", strconv.Itoa(jiraPageSize))
+
+		var resp jiraSearchResponse
+		if err := r.get(ctx, "/rest/api/2/search?"+query.Encode(), &resp); err != nil {
+			return nil, fmt.Errorf("search jira issues: %v", err)
+		}
+		if len(resp.Issues) < 1 {
+			break
+		}
+
+		issues = append(issues, resp.Issues...)
+		startAt += len(resp.Issues)
+		total = resp.Total
+	}
+
+	return issues, nil
+}
+
+func (r *JiraRepository) searchIssuesCloud(ctx context.Context, jql string) ([]jiraIssue, error) {
+	var issues []jiraIssue
+
+	for token := ""; ; {
+		query := url.Values{}
+		query.Set("jql", jql)
+		query.Set("fields", jiraSearchFields)
+		query.Set("maxResults", strconv.Itoa(jiraPageSize))
+		if len(token) > 0 {
+			query.Set("nextPageToken", token)
+		}
+
+		var resp jiraSearchJQLResponse
+		if err := r.get(ctx, "/rest/api/3/search/jql?"+query.Encode(), &resp); err != nil {
+			return nil, fmt.Errorf("search jira issues: %v", err)
+		}
+
+		issues = append(issues, resp.Issues...)
+		if resp.IsLast || len(resp.NextPageToken) < 1 || len(resp.Issues) < 1 {
+			break
+		}
+		token = resp.NextPageToken
+	}
+
+	return issues, nil
+}
+
+// IssueComments returns all comments of the issue.
+func (r *JiraRepository) IssueComments(ctx context.Context, key string) ([]jiraComment, error) {
+	var comments []jiraComment
+
+	for startAt, total := 0, 1; startAt < total; {
+		query := url.Values{}
+		query.Set("startAt", strconv.Itoa(startAt))
+		query.Set("maxResults", strconv.Itoa(jiraPageSize))
+
+		var resp jiraComments
+		if err := r.get(ctx, "/rest/api/2/issue/"+url.PathEscape(key)+"/comment?"+query.Encode(), &resp); err != nil {
+			return nil, fmt.Errorf("get comments of %s: %v", key, err)
+		}
+		if len(resp.Comments) < 1 {
+			break
+		}
+
+		comments = append(comments, resp.Comments...)
+		startAt += len(resp.Comments)
+		total = resp.Total
+	}
+
+	return comments, nil
+}
+
+// IssueWorklogs returns worklogs of the issue started after the search window start.
+func (r *JiraRepository) IssueWorklogs(ctx context.Context, key string, sc *models.SearchConfig) ([]jiraWorklog, error) {
+	var worklogs []jiraWorklog
+
+	for startAt, total := 0, 1; startAt < total; {
+		query := url.Values{}
+		query.Set("startAt", strconv.Itoa(startAt))
+		query.Set("maxResults", strconv.Itoa(jiraPageSize))
+		query.Set("startedAfter", strconv.FormatInt(sc.LastEditedTimeStart.UnixNano()/int64(time.Millisecond), 10))
+
+		var resp jiraWorklogs
+		if err := r.get(ctx, "/rest/api/2/issue/"+url.PathEscape(key)+"/worklog?"+query.Encode(), &resp); err != nil {
+			return nil, fmt.Errorf("get worklogs of %s: %v", key, err)
+		}
+		if len(resp.Worklogs) < 1 {
+			break
+		}
+
+		worklogs = append(worklogs, resp.Worklogs...)
+		startAt += len(resp.Worklogs)
+		total = resp.Total
+	}
+
+	return worklogs, nil
+}
+
+// QueryJiraUser returns the user the API token belongs to.
+func (r *JiraRepository) QueryJiraUser(ctx context.Context) (jiraUser, error) {
+	var user jiraUser
+	err := r.get(ctx, "/rest/api/2/myself", &user)
+	return user, err
+}
+
+func (r *JiraRepository) get(ctx context.Context, path string, v interface{}) error {
+	ctx, cancel := helpers.WithTimeout(ctx, r.Cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.BaseURL.String()+path, nil)
+	if err != nil {
+		return err
+	}
+	if len(r.Cfg.Username) > 0 {
+		req.SetBasicAuth(r.Cfg.Username, r.Cfg.APIToken)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+r.Cfg.APIToken)
+	}
+
+	return doJSON(r.Client, req, v)
+}
+
+// issueNotes returns comments and worklogs of the user made in the search window.
+func issueNotes(issue jiraIssue, user jiraUser, sc *models.SearchConfig) (notes []string) {
+	for _, c := range issue.Fields.Comment.Comments {
+		if c.Author.is(user) && inWindow(c.Updated.Time, sc) {
+			notes = append(notes, firstLine(string(c.Body)))
+		}
+	}
+	for _, w := range issue.Fields.Worklog.Worklogs {
+		if w.Author.is(user) && inWindow(w.Started.Time, sc) {
+			note := "Logged " + w.TimeSpent
+			if comment := firstLine(string(w.Comment)); len(comment) > 0 {
+				note += ": " + comment
+			}
+			notes = append(notes, note)
+		}
+	}
+	return
+}
+
+// inWindow reports whether t is inside the search window.
+func inWindow(t time.Time, sc *models.SearchConfig) bool {
+	return t.After(sc.LastEditedTimeStart) && t.Before(sc.LastEditedTimeEnd)
+}
+
+// firstLine returns the first non-empty line of s.
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); len(line) > 0 {
+			return line
+		}
+	}
+	return ""
+}
+
+// minutesSince returns whole minutes elapsed since t rounded up.
+func minutesSince(t time.Time) int {
+	m := time.Since(t).Minutes()
+	if m < 0 {
+		return 0
+	}
+	return int(math.Ceil(m))
+}
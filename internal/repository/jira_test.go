@@ -0,0 +1,258 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+func jiraTimeString(t time.Time) string {
+	return t.Format(jiraTimeLayout)
+}
+
+func TestJiraGetTasks(t *testing.T) {
+	now := time.Now()
+	sc := &models.SearchConfig{LastEditedTimeStart: now.Add(-24 * time.Hour), LastEditedTimeEnd: now}
+	inside := jiraTimeString(now.Add(-time.Hour))
+	outside := jiraTimeString(now.Add(-48 * time.Hour))
+	me := `{"accountId":"me"}`
+	other := `{"accountId":"other"}`
+
+	var searchQuery, worklogQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, token, ok := r.BasicAuth(); !ok || user != "john@example.com" || token != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/rest/api/2/myself":
+			fmt.Fprint(w, me)
+		case "/rest/api/2/search":
+			searchQuery = r.URL.RawQuery
+			// The search returns only the first page of comments and worklogs
+			fmt.Fprintf(w, `{"startAt":0,"maxResults":50,"total":1,"issues":[{"key":"ACME-1","fields":{
+				"summary":"Billing","project":{"key":"ACME"},
+				"comment":{"total":3,"comments":[{"author":%s,"body":"old","created":%q,"updated":%q}]},
+				"worklog":{"total":2,"worklogs":[{"author":%s,"timeSpent":"1h","started":%q}]}}}]}`,
+				me, outside, outside, me, outside)
+		case "/rest/api/2/issue/ACME-1/comment":
+			startAt, _ := strconv.Atoi(r.URL.Query().Get("startAt"))
+			comments := []string{
+				fmt.Sprintf(`{"author":%s,"body":"old","created":%q,"updated":%q}`, me, outside, outside),
+				fmt.Sprintf(`{"author":%s,"body":"Fixed rounding\nDetails","created":%q,"updated":%q}`, me, inside, inside),
+				fmt.Sprintf(`{"author":%s,"body":"Not mine","created":%q,"updated":%q}`, other, inside, inside),
+			}
+			// Two comments per page
+			end := startAt + 2
+			if end > len(comments) {
+				end = len(comments)
+			}
+			fmt.Fprintf(w, `{"startAt":%d,"maxResults":2,"total":3,"comments":[%s]}`, startAt, strings.Join(comments[startAt:end], ","))
+		case "/rest/api/2/issue/ACME-1/worklog":
+			worklogQuery = r.URL.RawQuery
+			fmt.Fprintf(w, `{"startAt":0,"maxResults":50,"total":1,"worklogs":[{"author":%s,"comment":"Review","timeSpent":"2h","started":%q}]}`, me, inside)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	// The fake server is not *.atlassian.net, so Jira Server search is used
+	repo, err := NewJiraRepository("jira", &config.JiraConfig{
+		BaseURL:  srv.URL + "/",
+		Username: "john@example.com",
+		APIToken: "secret",
+		JQL:      "project = ACME",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasks, err := repo.GetTasks(context.Background(), sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := models.Tasks{{
+		Title:    "ACME-1",
+		URL:      srv.URL + "/browse/ACME-1",
+		Projects: []string{"ACME"},
+		Notes:    []string{"Fixed rounding", "Logged 2h: Review"},
+	}}
+	if !reflect.DeepEqual(tasks, want) {
+		t.Errorf("GetTasks() = %+v, want %+v", tasks, want)
+	}
+
+	jql := mustQuery(t, searchQuery).Get("jql")
+	for _, clause := range []string{"assignee = currentUser()", "updated >= -144", "(project = ACME)", "ORDER BY updated DESC"} {
+		if !strings.Contains(jql, clause) {
+			t.Errorf("jql %q doesn't contain %q", jql, clause)
+		}
+	}
+	if fields := mustQuery(t, searchQuery).Get("fields"); fields != "summary,project,comment,worklog" {
+		t.Errorf("fields = %q", fields)
+	}
+	wantStartedAfter := strconv.FormatInt(sc.LastEditedTimeStart.UnixNano()/int64(time.Millisecond), 10)
+	if got := mustQuery(t, worklogQuery).Get("startedAfter"); got != wantStartedAfter {
+		t.Errorf("startedAfter = %q, want %q", got, wantStartedAfter)
+	}
+}
+
+func TestJiraBearerToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer pat" {
+			t.Errorf("Authorization = %q, want bearer token", got)
+		}
+		fmt.Fprint(w, `{"name":"john"}`)
+	}))
+	defer srv.Close()
+
+	repo, err := NewJiraRepository("jira", &config.JiraConfig{BaseURL: srv.URL, APIToken: "pat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := repo.QueryJiraUser(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "john" {
+		t.Errorf("user = %+v", user)
+	}
+}
+
+func TestJiraCloudSearch(t *testing.T) {
+	now := time.Now()
+	sc := &models.SearchConfig{LastEditedTimeStart: now.Add(-24 * time.Hour), LastEditedTimeEnd: now}
+	inside := jiraTimeString(now.Add(-time.Hour))
+	me := `{"accountId":"me"}`
+	// REST API v3 returns rich text in Atlassian Document Format
+	doc := func(text string) string {
+		return fmt.Sprintf(`{"type":"doc","version":1,"content":[{"type":"paragraph","content":[{"type":"text","text":%q},{"type":"hardBreak"},{"type":"text","text":"Details"}]}]}`, text)
+	}
+
+	var tokens []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/api/2/myself":
+			fmt.Fprint(w, me)
+		case "/rest/api/3/search/jql":
+			query := r.URL.Query()
+			if query.Get("fields") != "summary,project,comment,worklog" || !strings.Contains(query.Get("jql"), "assignee = currentUser()") {
+				http.Error(w, "bad query "+r.URL.RawQuery, http.StatusBadRequest)
+				return
+			}
+			token := query.Get("nextPageToken")
+			tokens = append(tokens, token)
+			switch token {
+			case "":
+				fmt.Fprintf(w, `{"issues":[{"key":"ACME-1","fields":{"summary":"Billing","project":{"key":"ACME"},
+					"comment":{"total":1,"comments":[{"author":%s,"body":%s,"created":%q,"updated":%q}]},
+					"worklog":{"total":0,"worklogs":[]}}}],"nextPageToken":"page-2","isLast":false}`, me, doc("Fixed rounding"), inside, inside)
+			case "page-2":
+				fmt.Fprintf(w, `{"issues":[{"key":"ACME-2","fields":{"summary":"Invoices","project":{"key":"ACME"},
+					"comment":{"total":0,"comments":[]},
+					"worklog":{"total":1,"worklogs":[{"author":%s,"comment":%s,"timeSpent":"1h","started":%q}]}}}],"isLast":true}`, me, doc("Review"), inside)
+			default:
+				http.Error(w, "unknown token", http.StatusBadRequest)
+			}
+		case "/rest/api/2/search":
+			http.Error(w, `{"errorMessages":["The requested API has been removed."]}`, http.StatusGone)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	repo, err := NewJiraRepository("jira", &config.JiraConfig{
+		BaseURL:    srv.URL,
+		Username:   "john@example.com",
+		APIToken:   "secret",
+		Deployment: "Cloud",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasks, err := repo.GetTasks(context.Background(), sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := models.Tasks{
+		{Title: "ACME-1", URL: srv.URL + "/browse/ACME-1", Projects: []string{"ACME"}, Notes: []string{"Fixed rounding"}},
+		{Title: "ACME-2", URL: srv.URL + "/browse/ACME-2", Projects: []string{"ACME"}, Notes: []string{"Logged 1h: Review"}},
+	}
+	if !reflect.DeepEqual(tasks, want) {
+		t.Errorf("GetTasks() = %+v, want %+v", tasks, want)
+	}
+	if !reflect.DeepEqual(tokens, []string{"", "page-2"}) {
+		t.Errorf("page tokens = %q, want first page and page-2", tokens)
+	}
+}
+
+func TestJiraDeployment(t *testing.T) {
+	tests := []struct {
+		baseURL    string
+		deployment string
+		want       string
+		wantErr    bool
+	}{
+		{baseURL: "https://acme.atlassian.net", want: "cloud"},
+		{baseURL: "https://ACME.Atlassian.net/", want: "cloud"},
+		{baseURL: "https://jira.acme.com", want: "server"},
+		{baseURL: "https://jira.acme.com", deployment: "cloud", want: "cloud"},
+		{baseURL: "https://acme.atlassian.net", deployment: "server", want: "server"},
+		{baseURL: "https://jira.acme.com", deployment: "datacenter", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		repo, err := NewJiraRepository("jira", &config.JiraConfig{BaseURL: tt.baseURL, Deployment: tt.deployment})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewJiraRepository(%q, %q) error = %v, want error %v", tt.baseURL, tt.deployment, err, tt.wantErr)
+			continue
+		}
+		if err == nil && repo.Cfg.Deployment != tt.want {
+			t.Errorf("NewJiraRepository(%q, %q) deployment = %q, want %q", tt.baseURL, tt.deployment, repo.Cfg.Deployment, tt.want)
+		}
+	}
+}
+
+func TestJiraTextFromDocument(t *testing.T) {
+	var c jiraComment
+	err := json.Unmarshal([]byte(`{"body":{"type":"doc","content":[
+		{"type":"heading","content":[{"type":"text","text":"Summary"}]},
+		{"type":"bulletList","content":[{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"one"}]}]}]}]}}`), &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Body != "Summary\none\n\n" {
+		t.Errorf("body = %q", c.Body)
+	}
+}
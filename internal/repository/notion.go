@@ -17,136 +17,300 @@ limitations under the License.
 package repository
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"net/http"
+	"os"
 	"sync"
 	"time"
 
 	"github.com/jomei/notionapi"
-	"github.com/nemca/taskgram/internal/config"
-	"github.com/nemca/taskgram/internal/models"
+	"github.com/nemca/taskgram/internal/cache"
+	"github.com/nemca/taskgram/internal/helpers"
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+	"github.com/nemca/taskgram/pkg/source"
+)
+
+const (
+	// notionDefaultConcurrency is the default number of pages fetched in parallel
+	notionDefaultConcurrency = 3
+	notionAPIURL             = "https://api.notion.com/v1"
+	// notionVersion is the API version used by notionapi
+	notionVersion = "2021-08-16"
+	// notionCacheSettleTime is the age of last_edited_time after which
+	// the block can be cached, the minute precision plus clock skew
+	notionCacheSettleTime = 2 * time.Minute
 )
 
 const (
 	propertyFilterAssign string = "Assign"
 	propertyProject      string = "Project"
 	propertyDescription  string = "Description"
+
+	// propertyTypeStatus is the native status property type unknown to notionapi
+	propertyTypeStatus notionapi.PropertyType = "status"
 )
 
+// supportedPropertyTypes are property types which notionapi can decode
+var supportedPropertyTypes = map[notionapi.PropertyType]bool{
+	notionapi.PropertyTypeTitle:          true,
+	notionapi.PropertyTypeRichText:       true,
+	notionapi.PropertyTypeText:           true,
+	notionapi.PropertyTypeNumber:         true,
+	notionapi.PropertyTypeSelect:         true,
+	notionapi.PropertyTypeMultiSelect:    true,
+	notionapi.PropertyTypeDate:           true,
+	notionapi.PropertyTypeFormula:        true,
+	notionapi.PropertyTypeRelation:       true,
+	notionapi.PropertyTypeRollup:         true,
+	notionapi.PropertyTypePeople:         true,
+	notionapi.PropertyTypeFiles:          true,
+	notionapi.PropertyTypeCheckbox:       true,
+	notionapi.PropertyTypeURL:            true,
+	notionapi.PropertyTypeEmail:          true,
+	notionapi.PropertyTypePhoneNumber:    true,
+	notionapi.PropertyTypeCreatedTime:    true,
+	notionapi.PropertyTypeCreatedBy:      true,
+	notionapi.PropertyTypeLastEditedTime: true,
+	notionapi.PropertyTypeLastEditedBy:   true,
+}
+
 var (
 	ErrNotFound = errors.New("not found")
+
+	// notionCache stores block children, nil if cache is disabled
+	notionCache *cache.Cache
 )
 
+func init() {
+	source.Register("notion", func(target *config.TargetsConfig) (source.Repository, error) {
+		return NewNotionRepository(target.Name, &target.Notion)
+	})
+}
+
 type NotionRepository struct {
 	Client *notionapi.Client
-	Cfg    *config.NotionConfig
-	Name   string
+	// HTTPClient is used for requests which are not supported by Client
+	HTTPClient *http.Client
+	Cfg        *config.NotionConfig
+	Name       string
 }
 
-func NewNotionRepository(name string, cfg *config.NotionConfig) (*NotionRepository, error) {
-	client := notionapi.NewClient(notionapi.Token(cfg.APIKey))
+// databaseQuery is a database query request.
+// Unlike notionapi.DatabaseQueryRequest it supports timestamp filters.
+type databaseQuery struct {
+	Filter      map[notionapi.FilterOperator][]interface{} `json:"filter,omitempty"`
+	StartCursor notionapi.Cursor                           `json:"start_cursor,omitempty"`
+}
 
-	// Search userID by username if it's not set explicitly
-	if len(cfg.UserID) < 1 {
-		user, err := QueryNotionUser(client, cfg.Username, cfg.Timeout)
-		if errors.Is(err, ErrNotFound) {
-			return nil, fmt.Errorf("User %q not found in Notion.", cfg.Username)
-		}
-		if err != nil {
-			return nil, err
-		}
-		cfg.UserID = user.ID.String()
-		fmt.Printf("WARNING: You userID in %q Notion target is %q. Please, add this ID to config 'notion_config.userID'.\n\n", name, cfg.UserID)
+// blockChildren is a cache entry of block children
+type blockChildren struct {
+	// LastEditedTime of the block
+	LastEditedTime time.Time        `json:"last_edited_time"`
+	Results        notionapi.Blocks `json:"results"`
+}
+
+// statusFilter filters pages by native status property which notionapi doesn't support
+type statusFilter struct {
+	Property string                           `json:"property"`
+	Status   *notionapi.SelectFilterCondition `json:"status"`
+}
+
+// timestampFilter filters pages by last_edited_time
+type timestampFilter struct {
+	Timestamp      string                         `json:"timestamp"`
+	LastEditedTime *notionapi.DateFilterCondition `json:"last_edited_time"`
+}
+
+// SetCache enables cache of Notion block trees, nil disables it.
+func SetCache(c *cache.Cache) {
+	notionCache = c
+}
+
+func NewNotionRepository(name string, cfg *config.NotionConfig) (*NotionRepository, error) {
+	client, err := newNotionClient(cfg.APIKey, cfg.BaseURL, cfg.RateLimit, cfg.MaxAttempts)
+	if err != nil {
+		return nil, err
 	}
 
 	return &NotionRepository{
-		Client: client,
+		Client:     client.api,
+		HTTPClient: client.http,
 		Cfg: &config.NotionConfig{
-			APIKey:          cfg.APIKey,
-			DatabaseID:      cfg.DatabaseID,
-			UserID:          cfg.UserID,
-			Username:        cfg.Username,
-			Timeout:         cfg.Timeout,
-			HeadingDoneName: cfg.HeadingDoneName,
-			HeadingToDoName: cfg.HeadingToDoName,
+			APIKey:           cfg.APIKey,
+			BaseURL:          cfg.BaseURL,
+			DatabaseID:       cfg.DatabaseID,
+			UserID:           cfg.UserID,
+			Username:         cfg.Username,
+			Timeout:          cfg.Timeout,
+			HeadingDoneName:  cfg.HeadingDoneName,
+			HeadingToDoName:  cfg.HeadingToDoName,
+			Concurrency:      cfg.Concurrency,
+			RateLimit:        cfg.RateLimit,
+			MaxAttempts:      cfg.MaxAttempts,
+			StatusProperty:   cfg.StatusProperty,
+			TerminalStatuses: cfg.TerminalStatuses,
 		},
 		Name: name,
 	}, nil
 }
 
-func (r *NotionRepository) GetTasks(cfg *config.Config, sc *models.SearchConfig) (doneTasks models.Tasks, todayTasks models.Tasks, err error) {
-	wg := new(sync.WaitGroup)
-	doneTaskCh := make(chan models.Task)
-	todayTaskCh := make(chan models.Task)
-	doneCh := make(chan struct{})
-	counter := 0
+// Fetch implements source.Repository interface
+func (r *NotionRepository) Fetch(ctx context.Context, sc *models.SearchConfig) (done models.Items, today models.Items, err error) {
+	// Search userID by username if it's not set explicitly
+	if len(r.Cfg.UserID) < 1 {
+		user, err := QueryNotionUser(ctx, r.Client, r.Cfg.Username, r.Cfg.Timeout)
+		if errors.Is(err, ErrNotFound) {
+			return done, today, fmt.Errorf("User %q not found in Notion.", r.Cfg.Username)
+		}
+		if err != nil {
+			return done, today, err
+		}
+		r.Cfg.UserID = user.ID.String()
+		fmt.Fprintf(os.Stderr, "WARNING: You userID in %q Notion target is %q. Please, add this ID to config 'notion_config.userID'.\n\n", r.Name, r.Cfg.UserID)
+	}
 
-	pageTasks, err := r.GetPages()
+	done.Tasks, today.Tasks, done.Warnings, today.Warnings, err = r.GetTasks(ctx, sc)
+	return
+}
+
+// GetTasks returns done and today tasks with warnings about tasks skipped because of errors.
+func (r *NotionRepository) GetTasks(ctx context.Context, sc *models.SearchConfig) (doneTasks, todayTasks models.Tasks, doneWarnings, todayWarnings []string, err error) {
+	// Only pages edited in the search window can have done notes
+	donePages, err := r.GetPages(ctx,
+		lastEditedFilter(&notionapi.DateFilterCondition{OnOrAfter: dateFilterTime(sc.LastEditedTimeStart.Truncate(time.Minute))}),
+		lastEditedFilter(&notionapi.DateFilterCondition{OnOrBefore: dateFilterTime(sc.LastEditedTimeEnd)}),
+	)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	// For todo tasks, we don't need to check last edit time and we will always show them
+	statusFilters, err := r.statusFilters(ctx)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	todayPages, err := r.GetPages(ctx, statusFilters...)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, nil, err
+	}
+
+	var jobs []notesJob
+	for i := range donePages {
+		page := &donePages[i]
+		// Notion filters by minutes, so check the exact time
+		if page.LastEditedTime.After(sc.LastEditedTimeStart) && page.LastEditedTime.Before(sc.LastEditedTimeEnd) {
+			jobs = append(jobs, notesJob{page: page, searchTime: sc.LastEditedTimeStart, heading: r.Cfg.HeadingDoneName, done: true})
+		}
+	}
+	for i := range todayPages {
+		jobs = append(jobs, notesJob{page: &todayPages[i], heading: r.Cfg.HeadingToDoName})
+	}
+
+	r.runJobs(ctx, jobs)
+
+	// Notes of cancelled requests are incomplete
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nil, nil, err
 	}
 
-	// Get done notes
-	for _, notionPage := range pageTasks {
-		if notionPage.LastEditedTime.After(sc.LastEditedTimeStart) && notionPage.LastEditedTime.Before(sc.LastEditedTimeEnd) {
-			wg.Add(1)
-			counter++
-			donePage := notionPage
-			go r.GetNotes(&donePage, sc.LastEditedTimeStart, r.Cfg.HeadingDoneName, doneTaskCh, wg, doneCh)
+	for _, job := range jobs {
+		if errors.Is(job.err, ErrNotFound) {
+			continue
 		}
-		// For todo tasks, we don't need to check last edit time and we will always show them
+		if job.err != nil {
+			title := job.task.Title
+			if len(title) < 1 {
+				title = job.page.URL
+			}
+			warning := fmt.Sprintf("%s: skipped %q: %v", r.Name, title, job.err)
+			if job.done {
+				doneWarnings = append(doneWarnings, warning)
+			} else {
+				todayWarnings = append(todayWarnings, warning)
+			}
+			continue
+		}
+		if job.done {
+			doneTasks = append(doneTasks, job.task)
+		} else {
+			todayTasks = append(todayTasks, job.task)
+		}
+	}
+
+	return doneTasks, todayTasks, doneWarnings, todayWarnings, nil
+}
+
+// notesJob is a request of notes under the heading of the page
+type notesJob struct {
+	page       *notionapi.Page
+	searchTime time.Time
+	heading    string
+	done       bool
+
+	task models.Task
+	err  error
+}
+
+// runJobs gets notes for jobs by a limited number of workers.
+// Results are stored in jobs.
+func (r *NotionRepository) runJobs(ctx context.Context, jobs []notesJob) {
+	concurrency := r.Cfg.Concurrency
+	if concurrency < 1 {
+		concurrency = notionDefaultConcurrency
+	}
+
+	jobCh := make(chan *notesJob)
+	wg := new(sync.WaitGroup)
+	for i := 0; i < concurrency; i++ {
 		wg.Add(1)
-		counter++
-		todayPage := notionPage
-		go r.GetNotes(&todayPage, time.Time{}, r.Cfg.HeadingToDoName, todayTaskCh, wg, doneCh)
+		go func() {
+			defer wg.Done()
+			for job := range jobCh {
+				job.task, job.err = r.GetNotes(ctx, job.page, job.searchTime, job.heading)
+			}
+		}()
 	}
 
-	for n := counter; n > 0; {
+	for i := range jobs {
 		select {
-		case doneTask := <-doneTaskCh:
-			doneTasks = append(doneTasks, doneTask)
-		case todayTask := <-todayTaskCh:
-			todayTasks = append(todayTasks, todayTask)
-		case <-doneCh:
-			n--
+		case jobCh <- &jobs[i]:
+		case <-ctx.Done():
 		}
-
-		if doneTaskCh == nil {
+		if ctx.Err() != nil {
 			break
 		}
 	}
-
+	close(jobCh)
 	wg.Wait()
-
-	return doneTasks, todayTasks, nil
 }
 
 // GetPages returns pages from database which has property Assign equals to user
-func (r *NotionRepository) GetPages() (output []notionapi.Page, err error) {
+// and match all additional filters
+func (r *NotionRepository) GetPages(ctx context.Context, filters ...interface{}) (output []notionapi.Page, err error) {
 	var pages []notionapi.Page
 	var cursor notionapi.Cursor
 
 	for hasMore := true; hasMore; {
-		databaseQueryRequest := &notionapi.DatabaseQueryRequest{
-			CompoundFilter: &notionapi.CompoundFilter{
-				notionapi.FilterOperatorAND: []notionapi.PropertyFilter{
-					{
+		query := &databaseQuery{
+			Filter: map[notionapi.FilterOperator][]interface{}{
+				notionapi.FilterOperatorAND: append([]interface{}{
+					notionapi.PropertyFilter{
 						Property: propertyFilterAssign,
 						People: &notionapi.PeopleFilterCondition{
 							Contains: r.Cfg.UserID,
 						},
 					},
-				},
+				}, filters...),
 			},
 			StartCursor: cursor,
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), r.Cfg.Timeout)
-		defer cancel()
-
-		resp, err := r.Client.Database.Query(ctx, notionapi.DatabaseID(r.Cfg.DatabaseID), databaseQueryRequest)
+		resp, err := r.queryDatabase(ctx, query)
 		if err != nil {
 			return nil, errors.New(err.Error())
 		}
@@ -159,19 +323,13 @@ func (r *NotionRepository) GetPages() (output []notionapi.Page, err error) {
 	return pages, nil
 }
 
-// GetNotes
-func (r *NotionRepository) GetNotes(page *notionapi.Page, searchTimeStart time.Time, headingName string, taskCh chan models.Task, wg *sync.WaitGroup, done chan struct{}) {
-	defer wg.Done()
-
-	var task models.Task
-	var err error
-
+// GetNotes returns the page as task with notes under the heading.
+// Returns ErrNotFound if the page has no such heading.
+func (r *NotionRepository) GetNotes(ctx context.Context, page *notionapi.Page, searchTimeStart time.Time, headingName string) (task models.Task, err error) {
 	// Title
 	task.Title, err = getPageTitle(page)
 	if err != nil {
-		log.Printf("get page title: %v", err)
-		done <- struct{}{}
-		return
+		return task, fmt.Errorf("get page title: %v", err)
 	}
 	task.URL = page.URL
 	// Try to get page property Project with type multiselect
@@ -186,138 +344,133 @@ func (r *NotionRepository) GetNotes(page *notionapi.Page, searchTimeStart time.T
 	}
 	// Workflow notes
 	// get page content
-	ctx, cancel := context.WithTimeout(context.Background(), r.Cfg.Timeout)
-	defer cancel()
-
-	pageContent, err := r.Client.Block.Get(ctx, notionapi.BlockID(page.ID))
+	pageContent, err := r.children(ctx, notionapi.BlockID(page.ID), page.LastEditedTime)
 	if err != nil {
-		log.Printf("get page content: %v", err)
-		done <- struct{}{}
-		return
+		return task, fmt.Errorf("get page content: %v", err)
 	}
 	// Get heading block by name
-	heading, err := r.SearchHeading(pageContent.GetID(), searchTimeStart, headingName)
-	if errors.Is(err, ErrNotFound) {
-		done <- struct{}{}
-		return
-	}
-	if err != nil && !errors.Is(err, ErrNotFound) {
-		fmt.Printf("get children headings: %v", err)
-		done <- struct{}{}
-		return
-	}
-	// Get notes
-	task.Notes, err = r.SearchNotes(heading.GetID(), searchTimeStart)
-	if err != nil && !errors.Is(err, ErrNotFound) {
-		log.Printf("get workflow notes: %v", err)
-		done <- struct{}{}
-		return
-	}
-
-	taskCh <- task
-	done <- struct{}{}
-}
-
-func (r *NotionRepository) SearchHeading(blockID notionapi.BlockID, searchTime time.Time, name string) (notionapi.Block, error) {
-	var cursor notionapi.Cursor
-
-	for hasMore := true; hasMore; {
-		pagination := &notionapi.Pagination{
-			StartCursor: cursor,
-			PageSize:    300,
-		}
+	heading, err := SearchHeading(pageContent, searchTimeStart, headingName)
+	if err != nil {
+		return task, err
+	}
+	// Get notes, they are refetched only if the heading is edited
+	var headingVersion time.Time
+	if t := heading.GetLastEditedTime(); t != nil {
+		headingVersion = *t
+	}
+	notes, err := r.children(ctx, heading.GetID(), headingVersion)
+	if err != nil {
+		return task, fmt.Errorf("get workflow notes: %v", err)
+	}
+	task.Notes = SearchNotes(notes, searchTimeStart)
 
-		ctx, cancel := context.WithTimeout(context.Background(), r.Cfg.Timeout)
-		defer cancel()
+	return task, nil
+}
 
-		resp, err := r.Client.Block.GetChildren(ctx, blockID, pagination)
-		if err != nil {
-			return nil, err
-		}
-		for _, block := range resp.Results {
-			switch block.GetType() {
-			case "heading_1":
-				if h, ok := block.(*notionapi.Heading1Block); ok {
-					if block.GetLastEditedTime().After(searchTime) {
-						if getRichText(h.Heading1.Text) == name {
-							return h, nil
-						}
+// SearchHeading returns the heading block with the name edited after searchTime.
+// Returns ErrNotFound if there is no such heading.
+func SearchHeading(blocks notionapi.Blocks, searchTime time.Time, name string) (notionapi.Block, error) {
+	for _, block := range blocks {
+		switch block.GetType() {
+		case "heading_1":
+			if h, ok := block.(*notionapi.Heading1Block); ok {
+				if block.GetLastEditedTime().After(searchTime) {
+					if getRichText(h.Heading1.Text) == name {
+						return h, nil
 					}
 				}
-			case "heading_2":
-				if h, ok := block.(*notionapi.Heading2Block); ok {
-					if block.GetLastEditedTime().After(searchTime) {
-						if getRichText(h.Heading2.Text) == name {
-							return h, nil
-						}
+			}
+		case "heading_2":
+			if h, ok := block.(*notionapi.Heading2Block); ok {
+				if block.GetLastEditedTime().After(searchTime) {
+					if getRichText(h.Heading2.Text) == name {
+						return h, nil
 					}
 				}
-			case "heading_3":
-				if h, ok := block.(*notionapi.Heading3Block); ok {
-					if block.GetLastEditedTime().After(searchTime) {
-						if getRichText(h.Heading3.Text) == name {
-							return h, nil
-						}
+			}
+		case "heading_3":
+			if h, ok := block.(*notionapi.Heading3Block); ok {
+				if block.GetLastEditedTime().After(searchTime) {
+					if getRichText(h.Heading3.Text) == name {
+						return h, nil
 					}
 				}
 			}
 		}
-		hasMore = resp.HasMore
-		cursor = notionapi.Cursor(resp.NextCursor)
 	}
 	// not found
 	return nil, ErrNotFound
 }
 
-func (r *NotionRepository) SearchNotes(blockID notionapi.BlockID, searchTime time.Time) ([]string, error) {
+// SearchNotes returns texts of paragraphs and list items edited after searchTime.
+func SearchNotes(blocks notionapi.Blocks, searchTime time.Time) []string {
 	var notes []string
-	var cursor notionapi.Cursor
+	for _, block := range blocks {
+		switch block.GetType() {
+		case "paragraph":
+			if paragraphBlock, ok := block.(*notionapi.ParagraphBlock); ok {
+				if paragraphBlock.GetLastEditedTime().After(searchTime) {
+					notes = append(notes, getRichText(paragraphBlock.Paragraph.Text))
+				}
+			}
+		case "bulleted_list_item":
+			if bulletListItem, ok := block.(*notionapi.BulletedListItemBlock); ok {
+				if bulletListItem.GetLastEditedTime().After(searchTime) {
+					notes = append(notes, getRichText(bulletListItem.BulletedListItem.Text))
+				}
+			}
+		case "numbered_list_item":
+			if numberListItem, ok := block.(*notionapi.NumberedListItemBlock); ok {
+				if numberListItem.GetLastEditedTime().After(searchTime) {
+					notes = append(notes, getRichText(numberListItem.NumberedListItem.Text))
+				}
+			}
+		}
+	}
+	return notes
+}
 
+// children returns all children of the block.
+// version is the last_edited_time of the block, it changes when children
+// are added or edited, so cached children of the same version are up to date.
+// Zero version disables the cache.
+func (r *NotionRepository) children(ctx context.Context, blockID notionapi.BlockID, version time.Time) (notionapi.Blocks, error) {
+	key := "notion/" + string(blockID)
+	var cached blockChildren
+	if notionCache != nil && !version.IsZero() && notionCache.Get(key, &cached) && cached.LastEditedTime.Equal(version) {
+		return cached.Results, nil
+	}
+
+	var blocks notionapi.Blocks
+	var cursor notionapi.Cursor
 	for hasMore := true; hasMore; {
 		pagination := &notionapi.Pagination{
 			StartCursor: cursor,
-			PageSize:    300,
+			PageSize:    100,
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), r.Cfg.Timeout)
-		defer cancel()
-
-		resp, err := r.Client.Block.GetChildren(ctx, blockID, pagination)
+		resp, err := r.getChildren(ctx, blockID, pagination)
 		if err != nil {
 			return nil, err
 		}
-		for _, block := range resp.Results {
-			switch block.GetType() {
-			case "paragraph":
-				if paragraphBlock, ok := block.(*notionapi.ParagraphBlock); ok {
-					if paragraphBlock.GetLastEditedTime().After(searchTime) {
-						notes = append(notes, getRichText(paragraphBlock.Paragraph.Text))
-					}
-				}
-			case "bulleted_list_item":
-				if bulletListItem, ok := block.(*notionapi.BulletedListItemBlock); ok {
-					if bulletListItem.GetLastEditedTime().After(searchTime) {
-						notes = append(notes, getRichText(bulletListItem.BulletedListItem.Text))
-					}
-				}
-			case "numbered_list_item":
-				if numberListItem, ok := block.(*notionapi.NumberedListItemBlock); ok {
-					if numberListItem.GetLastEditedTime().After(searchTime) {
-						notes = append(notes, getRichText(numberListItem.NumberedListItem.Text))
-					}
-				}
-			}
-		}
+		blocks = append(blocks, resp.Results...)
 		hasMore = resp.HasMore
 		cursor = notionapi.Cursor(resp.NextCursor)
 	}
-	// Not found
-	return notes, ErrNotFound
+
+	// Notion rounds last_edited_time down to a minute, so the block
+	// can be edited again with the same version until the minute is over
+	if notionCache != nil && !version.IsZero() && time.Since(version) > notionCacheSettleTime {
+		if err := notionCache.Put(key, blockChildren{LastEditedTime: version, Results: blocks}); err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: write cache: %v\n", err)
+		}
+	}
+	return blocks, nil
 }
 
 // QueryNotionUser find user in Notion by user name.
 // Returns ErrNotFound if user not found.
-func QueryNotionUser(client *notionapi.Client, username string, timeout time.Duration) (notionapi.User, error) {
+func QueryNotionUser(ctx context.Context, client *notionapi.Client, username string, timeout time.Duration) (notionapi.User, error) {
 	var cursor notionapi.Cursor
 
 	for hasMore := true; hasMore; {
@@ -326,10 +479,9 @@ func QueryNotionUser(client *notionapi.Client, username string, timeout time.Dur
 			PageSize:    300,
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), timeout)
-		defer cancel()
-
-		resp, err := client.User.List(ctx, pagination)
+		reqCtx, cancel := helpers.WithTimeout(ctx, timeout)
+		resp, err := client.User.List(reqCtx, pagination)
+		cancel()
 		if err != nil {
 			return notionapi.User{}, err
 		}
@@ -346,6 +498,139 @@ func QueryNotionUser(client *notionapi.Client, username string, timeout time.Dur
 	// Not found
 	return notionapi.User{}, ErrNotFound
 }
+
+// statusFilters returns filters of pages with non-terminal status.
+// The status property is a select or a native status property,
+// its type is read from the database schema.
+func (r *NotionRepository) statusFilters(ctx context.Context) ([]interface{}, error) {
+	if len(r.Cfg.StatusProperty) < 1 {
+		return nil, nil
+	}
+
+	var database struct {
+		Properties map[string]struct {
+			Type notionapi.PropertyType `json:"type"`
+		} `json:"properties"`
+	}
+	if err := r.request(ctx, http.MethodGet, "/databases/"+r.Cfg.DatabaseID, nil, &database); err != nil {
+		return nil, fmt.Errorf("get database schema: %v", err)
+	}
+	property, ok := database.Properties[r.Cfg.StatusProperty]
+	if !ok {
+		return nil, fmt.Errorf("status property %q not found in the database", r.Cfg.StatusProperty)
+	}
+
+	var filters []interface{}
+	for _, status := range r.Cfg.TerminalStatuses {
+		condition := &notionapi.SelectFilterCondition{DoesNotEqual: status}
+		switch property.Type {
+		case propertyTypeStatus:
+			filters = append(filters, statusFilter{Property: r.Cfg.StatusProperty, Status: condition})
+		case notionapi.PropertyTypeSelect:
+			filters = append(filters, notionapi.PropertyFilter{Property: r.Cfg.StatusProperty, Select: condition})
+		default:
+			return nil, fmt.Errorf("status property %q has type %s, expected select or status", r.Cfg.StatusProperty, property.Type)
+		}
+	}
+	return filters, nil
+}
+
+// queryDatabase sends the query directly because notionapi doesn't support timestamp filters
+func (r *NotionRepository) queryDatabase(ctx context.Context, query *databaseQuery) (*notionapi.DatabaseQueryResponse, error) {
+	var raw struct {
+		Results    []json.RawMessage `json:"results"`
+		HasMore    bool              `json:"has_more"`
+		NextCursor notionapi.Cursor  `json:"next_cursor"`
+	}
+	if err := r.request(ctx, http.MethodPost, fmt.Sprintf("/databases/%s/query", r.Cfg.DatabaseID), query, &raw); err != nil {
+		return nil, err
+	}
+
+	resp := &notionapi.DatabaseQueryResponse{HasMore: raw.HasMore, NextCursor: raw.NextCursor}
+	for _, b := range raw.Results {
+		page, err := decodePage(b)
+		if err != nil {
+			return nil, fmt.Errorf("decode page: %v", err)
+		}
+		resp.Results = append(resp.Results, page)
+	}
+	return resp, nil
+}
+
+// request sends body as JSON to Notion API and decodes the response to v
+func (r *NotionRepository) request(ctx context.Context, method, path string, body, v interface{}) error {
+	ctx, cancel := helpers.WithTimeout(ctx, r.Cfg.Timeout)
+	defer cancel()
+
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, notionAPIURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+r.Cfg.APIKey)
+	req.Header.Set("Notion-Version", notionVersion)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return doJSON(r.HTTPClient, req, v)
+}
+
+// decodePage decodes the page skipping properties of types unknown to notionapi,
+// e.g. status, which otherwise fail decoding of the whole page
+func decodePage(b []byte) (notionapi.Page, error) {
+	var page notionapi.Page
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return page, err
+	}
+	var properties map[string]json.RawMessage
+	if err := json.Unmarshal(raw["properties"], &properties); err == nil {
+		for name, p := range properties {
+			var property struct {
+				Type notionapi.PropertyType `json:"type"`
+			}
+			if err := json.Unmarshal(p, &property); err != nil || !supportedPropertyTypes[property.Type] {
+				delete(properties, name)
+			}
+		}
+		if raw["properties"], err = json.Marshal(properties); err != nil {
+			return page, err
+		}
+	}
+
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return page, err
+	}
+	err = json.Unmarshal(b, &page)
+	return page, err
+}
+
+func lastEditedFilter(condition *notionapi.DateFilterCondition) timestampFilter {
+	return timestampFilter{Timestamp: "last_edited_time", LastEditedTime: condition}
+}
+
+func dateFilterTime(t time.Time) *notionapi.Date {
+	d := notionapi.Date(t)
+	return &d
+}
+
+func (r *NotionRepository) getChildren(ctx context.Context, blockID notionapi.BlockID, pagination *notionapi.Pagination) (*notionapi.GetChildrenResponse, error) {
+	ctx, cancel := helpers.WithTimeout(ctx, r.Cfg.Timeout)
+	defer cancel()
+
+	return r.Client.Block.GetChildren(ctx, blockID, pagination)
+}
+
 func getPageTitle(page *notionapi.Page) (string, error) {
 	if page == nil {
 		return "", fmt.Errorf("cannot read title, nil page")
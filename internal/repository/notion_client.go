@@ -0,0 +1,223 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repository
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jomei/notionapi"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// notionDefaultRateLimit is the average rate limit of Notion API, requests per second
+	notionDefaultRateLimit   = 3
+	notionDefaultMaxAttempts = 5
+	// Backoff between attempts if the response has no Retry-After header
+	notionRetryBaseDelay = 500 * time.Millisecond
+	notionRetryMaxDelay  = 30 * time.Second
+)
+
+var (
+	notionClientsMu sync.Mutex
+	notionClients   = make(map[string]notionClient)
+	notionLimiters  = make(map[string]*rate.Limiter)
+
+	jitterMu sync.Mutex
+	jitter   = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// notionClient is Notion API client with its HTTP client
+// for requests which are not supported by notionapi
+type notionClient struct {
+	api  *notionapi.Client
+	http *http.Client
+}
+
+// NewNotionClient returns Notion API client for the API key.
+// Clients are shared by all targets and sinks with the same API key and base URL.
+// If baseURL is set, requests are sent to it instead of api.notion.com,
+// e.g. to a mock server.
+// Requests with the same API key are limited to rateLimit requests per second,
+// if targets set different limits the lowest one is used.
+// Requests failed with 429 and reads failed with 502, 503 or 504
+// are retried up to maxAttempts times.
+func NewNotionClient(apiKey, baseURL string, rateLimit float64, maxAttempts int) (*notionapi.Client, error) {
+	client, err := newNotionClient(apiKey, baseURL, rateLimit, maxAttempts)
+	return client.api, err
+}
+
+func newNotionClient(apiKey, baseURL string, rateLimit float64, maxAttempts int) (notionClient, error) {
+	notionClientsMu.Lock()
+	defer notionClientsMu.Unlock()
+
+	if rateLimit <= 0 {
+		rateLimit = notionDefaultRateLimit
+	}
+	limiter, ok := notionLimiters[apiKey]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(rateLimit), 1)
+		notionLimiters[apiKey] = limiter
+	} else if rate.Limit(rateLimit) < limiter.Limit() {
+		limiter.SetLimit(rate.Limit(rateLimit))
+	}
+
+	if maxAttempts < 1 {
+		maxAttempts = notionDefaultMaxAttempts
+	}
+
+	key := baseURL + "\x00" + apiKey + "\x00" + strconv.Itoa(maxAttempts)
+	if client, ok := notionClients[key]; ok {
+		return client, nil
+	}
+
+	transport := http.DefaultTransport
+	if len(baseURL) > 0 {
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return notionClient{}, fmt.Errorf("parse Notion base URL: %v", err)
+		}
+		transport = &rewriteTransport{base: u, next: transport}
+	}
+	transport = &rateLimitTransport{limiter: limiter, next: transport}
+	transport = &retryTransport{maxAttempts: maxAttempts, next: transport}
+
+	httpClient := &http.Client{Transport: transport}
+	client := notionClient{
+		api:  notionapi.NewClient(notionapi.Token(apiKey), notionapi.WithHTTPClient(httpClient)),
+		http: httpClient,
+	}
+	notionClients[key] = client
+
+	return client, nil
+}
+
+// retryTransport retries requests on rate limit and gateway errors
+// with exponential backoff and jitter, honouring Retry-After header.
+// Gateway errors are retried only for GET and HEAD requests,
+// because a write may be applied even if the gateway failed.
+type retryTransport struct {
+	maxAttempts int
+	next        http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper interface
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		r := req
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(req.Context())
+			r.Body = body
+		}
+
+		resp, err := t.next.RoundTrip(r)
+		if err != nil || attempt >= t.maxAttempts || !retryable(req.Method, resp.StatusCode) {
+			return resp, err
+		}
+
+		delay := retryDelay(resp, attempt)
+		_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryable returns true if the request may be sent again after the response status.
+// Rate limited requests are not processed, so they are retried for any method.
+func retryable(method string, code int) bool {
+	switch code {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return method == http.MethodGet || method == http.MethodHead
+	}
+	return false
+}
+
+// retryDelay returns the delay from Retry-After header
+// or exponential backoff with jitter for the attempt.
+func retryDelay(resp *http.Response, attempt int) time.Duration {
+	if retryAfter := resp.Header.Get("Retry-After"); len(retryAfter) > 0 {
+		if sec, err := strconv.Atoi(retryAfter); err == nil && sec >= 0 {
+			return time.Duration(sec) * time.Second
+		}
+		if t, err := http.ParseTime(retryAfter); err == nil {
+			if d := time.Until(t); d > 0 {
+				return d
+			}
+			return 0
+		}
+	}
+
+	backoff := notionRetryBaseDelay << (attempt - 1)
+	if backoff > notionRetryMaxDelay || backoff <= 0 {
+		backoff = notionRetryMaxDelay
+	}
+	// Equal jitter: a half of the backoff plus a random part of the other half
+	jitterMu.Lock()
+	defer jitterMu.Unlock()
+	return backoff/2 + time.Duration(jitter.Int63n(int64(backoff/2)+1))
+}
+
+// rateLimitTransport waits for the limiter before each request
+type rateLimitTransport struct {
+	limiter *rate.Limiter
+	next    http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper interface
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
+
+// rewriteTransport sends requests to the base URL keeping the path
+type rewriteTransport struct {
+	base *url.URL
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper interface
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	r.URL.Scheme = t.base.Scheme
+	r.URL.Host = t.base.Host
+	r.URL.Path = t.base.Path + req.URL.Path
+	r.Host = t.base.Host
+	return t.next.RoundTrip(r)
+}
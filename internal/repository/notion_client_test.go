@@ -0,0 +1,144 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repository
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		statuses     []int
+		wantStatus   int
+		wantAttempts int
+	}{
+		{name: "rate limited post", method: http.MethodPost, statuses: []int{429, 200}, wantStatus: 200, wantAttempts: 2},
+		{name: "gateway error get", method: http.MethodGet, statuses: []int{502, 504, 200}, wantStatus: 200, wantAttempts: 3},
+		{name: "gateway error post", method: http.MethodPost, statuses: []int{503, 200}, wantStatus: 503, wantAttempts: 1},
+		{name: "gateway error patch", method: http.MethodPatch, statuses: []int{504, 200}, wantStatus: 504, wantAttempts: 1},
+		{name: "client error", method: http.MethodGet, statuses: []int{400, 200}, wantStatus: 400, wantAttempts: 1},
+		{name: "attempts exhausted", method: http.MethodGet, statuses: []int{429, 429, 429, 200}, wantStatus: 429, wantAttempts: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var bodies []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := ioutil.ReadAll(r.Body)
+				mu.Lock()
+				bodies = append(bodies, string(b))
+				status := tt.statuses[len(bodies)-1]
+				mu.Unlock()
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(status)
+			}))
+			defer srv.Close()
+
+			client := &http.Client{Transport: &retryTransport{maxAttempts: 3, next: http.DefaultTransport}}
+			var body io.Reader
+			if tt.method != http.MethodGet {
+				body = strings.NewReader(`{"children":[]}`)
+			}
+			req, err := http.NewRequest(tt.method, srv.URL, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if len(bodies) != tt.wantAttempts {
+				t.Errorf("got %d attempts, want %d", len(bodies), tt.wantAttempts)
+			}
+			// Retries send the same body
+			for i, b := range bodies {
+				if b != bodies[0] {
+					t.Errorf("attempt %d body = %q, want %q", i+1, b, bodies[0])
+				}
+			}
+		})
+	}
+}
+
+func TestRetryTransportHonoursRetryAfter(t *testing.T) {
+	var times []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		times = append(times, time.Now())
+		if len(times) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &retryTransport{maxAttempts: 2, next: http.DefaultTransport}}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || len(times) != 2 {
+		t.Fatalf("status = %d after %d attempts, want 200 after 2", resp.StatusCode, len(times))
+	}
+	if d := times[1].Sub(times[0]); d < time.Second {
+		t.Errorf("retried after %v, want Retry-After 1s", d)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	header := func(v string) *http.Response {
+		resp := &http.Response{Header: http.Header{}}
+		if len(v) > 0 {
+			resp.Header.Set("Retry-After", v)
+		}
+		return resp
+	}
+
+	if d := retryDelay(header("3"), 1); d != 3*time.Second {
+		t.Errorf("Retry-After seconds delay = %v, want 3s", d)
+	}
+	if d := retryDelay(header(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)), 1); d != 0 {
+		t.Errorf("Retry-After date in the past delay = %v, want 0", d)
+	}
+	for attempt := 1; attempt <= 10; attempt++ {
+		backoff := notionRetryBaseDelay << (attempt - 1)
+		if backoff > notionRetryMaxDelay {
+			backoff = notionRetryMaxDelay
+		}
+		if d := retryDelay(header(""), attempt); d < backoff/2 || d > backoff {
+			t.Errorf("attempt %d backoff = %v, want between %v and %v", attempt, d, backoff/2, backoff)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jomei/notionapi"
+	"github.com/nemca/taskgram/internal/cache"
+	"github.com/nemca/taskgram/pkg/config"
+)
+
+// notionPageJSON returns a database page with a native status property unknown to notionapi
+func notionPageJSON(id, title string) string {
+	return fmt.Sprintf(`{"object":"page","id":%q,"url":"https://www.notion.so/%s","last_edited_time":"2026-01-05T09:00:00.000Z",
+		"properties":{
+			"Description":{"id":"title","type":"title","title":[{"type":"text","text":{"content":%q},"plain_text":%q}]},
+			"Project":{"id":"p","type":"multi_select","multi_select":[{"name":"Billing"}]},
+			"Status":{"id":"s","type":"status","status":{"name":"In progress","color":"blue"}},
+			"Unique":{"id":"u","type":"unique_id","unique_id":{"prefix":"ACME","number":1}}
+		}}`, id, id, title, title)
+}
+
+// fakeNotionAPI serves database schema, database query and block children.
+// Query results are paged by one page.
+type fakeNotionAPI struct {
+	mu sync.Mutex
+	// statusType is the type of Status property in the database schema
+	statusType string
+	// children are blocks by parent ID
+	children map[string][]string
+
+	queries       []map[string]interface{}
+	childRequests map[string]int
+}
+
+func (f *fakeNotionAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer secret_test" || len(r.Header.Get("Notion-Version")) < 1 {
+		http.Error(w, `{"object":"error","status":401,"code":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/databases/db-1":
+		fmt.Fprintf(w, `{"object":"database","id":"db-1","properties":{"Status":{"id":"s","name":"Status","type":%q}}}`, f.statusType)
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/databases/db-1/query":
+		var query map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.queries = append(f.queries, query)
+		if query["start_cursor"] == "cursor-2" {
+			fmt.Fprintf(w, `{"object":"list","results":[%s],"has_more":false,"next_cursor":null}`, notionPageJSON("page-2", "Invoices"))
+			return
+		}
+		fmt.Fprintf(w, `{"object":"list","results":[%s],"has_more":true,"next_cursor":"cursor-2"}`, notionPageJSON("page-1", "Billing"))
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/blocks/") && strings.HasSuffix(r.URL.Path, "/children"):
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/blocks/"), "/children")
+		if f.childRequests == nil {
+			f.childRequests = make(map[string]int)
+		}
+		f.childRequests[id]++
+		fmt.Fprintf(w, `{"object":"list","results":[%s],"has_more":false}`, strings.Join(f.children[id], ","))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestNotionRepository(t *testing.T, f *fakeNotionAPI, cfg config.NotionConfig) *NotionRepository {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	cfg.APIKey = "secret_test"
+	cfg.BaseURL = srv.URL
+	cfg.DatabaseID = "db-1"
+	cfg.UserID = "user-1"
+	// Tests don't wait for the Notion rate limit
+	cfg.RateLimit = 1000
+	r, err := NewNotionRepository("notion", &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// jsonValue decodes JSON to generic value for comparison with decoded requests
+func jsonValue(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("decode %s: %v", s, err)
+	}
+	return v
+}
+
+func TestNotionGetPagesWithTimestampFilter(t *testing.T) {
+	f := &fakeNotionAPI{}
+	r := newTestNotionRepository(t, f, config.NotionConfig{})
+
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	pages, err := r.GetPages(context.Background(),
+		lastEditedFilter(&notionapi.DateFilterCondition{OnOrAfter: dateFilterTime(start)}),
+		lastEditedFilter(&notionapi.DateFilterCondition{OnOrBefore: dateFilterTime(start.Add(24 * time.Hour))}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both pages of results are read
+	if len(pages) != 2 || pages[0].ID != "page-1" || pages[1].ID != "page-2" {
+		t.Fatalf("got pages %+v, want page-1 and page-2", pages)
+	}
+	if len(f.queries) != 2 {
+		t.Fatalf("got %d queries, want 2", len(f.queries))
+	}
+
+	filter := `{"and":[
+		{"property":"Assign","people":{"contains":"user-1"}},
+		{"timestamp":"last_edited_time","last_edited_time":{"on_or_after":"2026-01-05T09:00:00Z"}},
+		{"timestamp":"last_edited_time","last_edited_time":{"on_or_before":"2026-01-06T09:00:00Z"}}]}`
+	want := []map[string]interface{}{
+		{"filter": jsonValue(t, filter)},
+		{"filter": jsonValue(t, filter), "start_cursor": "cursor-2"},
+	}
+	if !reflect.DeepEqual(f.queries, want) {
+		got, _ := json.Marshal(f.queries)
+		t.Errorf("queries = %s", got)
+	}
+}
+
+func TestNotionStatusFilters(t *testing.T) {
+	tests := []struct {
+		statusType string
+		property   string
+		want       string
+		wantErr    string
+	}{
+		{
+			statusType: "status",
+			property:   "Status",
+			want: `[{"property":"Status","status":{"does_not_equal":"Done"}},
+				{"property":"Status","status":{"does_not_equal":"Canceled"}}]`,
+		},
+		{
+			statusType: "select",
+			property:   "Status",
+			want: `[{"property":"Status","select":{"does_not_equal":"Done"}},
+				{"property":"Status","select":{"does_not_equal":"Canceled"}}]`,
+		},
+		{statusType: "rich_text", property: "Status", wantErr: `status property "Status" has type rich_text, expected select or status`},
+		{statusType: "status", property: "State", wantErr: `status property "State" not found in the database`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.statusType+"/"+tt.property, func(t *testing.T) {
+			f := &fakeNotionAPI{statusType: tt.statusType}
+			r := newTestNotionRepository(t, f, config.NotionConfig{StatusProperty: tt.property, TerminalStatuses: []string{"Done", "Canceled"}})
+
+			filters, err := r.statusFilters(context.Background())
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("statusFilters() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The filters are sent in the database query
+			if _, err := r.GetPages(context.Background(), filters...); err != nil {
+				t.Fatal(err)
+			}
+			and := f.queries[0]["filter"].(map[string]interface{})["and"].([]interface{})
+			if got, want := and[1:], jsonValue(t, tt.want); !reflect.DeepEqual(got, want) {
+				b, _ := json.Marshal(got)
+				t.Errorf("status filters = %s, want %s", b, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotionStatusFiltersDisabled(t *testing.T) {
+	r := newTestNotionRepository(t, &fakeNotionAPI{}, config.NotionConfig{})
+	filters, err := r.statusFilters(context.Background())
+	if err != nil || filters != nil {
+		t.Errorf("statusFilters() = %v, %v, want no filters without a request", filters, err)
+	}
+}
+
+func TestDecodePage(t *testing.T) {
+	page, err := decodePage([]byte(notionPageJSON("page-1", "Billing")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	title, err := getPageTitle(&page)
+	if err != nil || title != "Billing" {
+		t.Errorf("title = %q, %v, want Billing", title, err)
+	}
+	if page.URL != "https://www.notion.so/page-1" || !page.LastEditedTime.Equal(time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("page = %+v", page)
+	}
+	// Properties of unknown types are dropped instead of failing the page
+	for _, name := range []string{"Status", "Unique"} {
+		if _, ok := page.Properties[name]; ok {
+			t.Errorf("property %s is decoded", name)
+		}
+	}
+	if project, ok := page.Properties["Project"].(*notionapi.MultiSelectProperty); !ok || project.MultiSelect[0].Name != "Billing" {
+		t.Errorf("Project = %+v", page.Properties["Project"])
+	}
+
+	if _, err := decodePage([]byte(`[]`)); err == nil {
+		t.Error("decodePage() of an array succeeded")
+	}
+}
+
+func TestNotionChildrenCache(t *testing.T) {
+	c, err := cache.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetCache(c)
+	t.Cleanup(func() { SetCache(nil) })
+
+	paragraph := `{"object":"block","id":"%s","type":"paragraph","paragraph":{"text":[{"type":"text","text":{"content":"Fixed rounding"},"plain_text":"Fixed rounding"}]}}`
+	f := &fakeNotionAPI{children: map[string][]string{
+		"old":    {fmt.Sprintf(paragraph, "old-1")},
+		"recent": {fmt.Sprintf(paragraph, "recent-1")},
+		"zero":   {fmt.Sprintf(paragraph, "zero-1")},
+	}}
+	r := newTestNotionRepository(t, f, config.NotionConfig{})
+
+	version := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	tests := []struct {
+		block        notionapi.BlockID
+		version      time.Time
+		wantRequests int
+	}{
+		// The first fetch fills the cache, the second one is served from it
+		{block: "old", version: version, wantRequests: 1},
+		{block: "old", version: version, wantRequests: 1},
+		// The block was edited since the cached version
+		{block: "old", version: version.Add(time.Minute), wantRequests: 2},
+		{block: "old", version: version.Add(time.Minute), wantRequests: 2},
+		// The block can still change within the same version
+		{block: "recent", version: time.Now().Truncate(time.Minute), wantRequests: 1},
+		{block: "recent", version: time.Now().Truncate(time.Minute), wantRequests: 2},
+		// Without a version the cache is not used
+		{block: "zero", wantRequests: 1},
+		{block: "zero", wantRequests: 2},
+	}
+
+	for i, tt := range tests {
+		blocks, err := r.children(context.Background(), tt.block, tt.version)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if len(blocks) != 1 {
+			t.Fatalf("#%d: children(%s) = %+v, want one block", i, tt.block, blocks)
+		}
+		if p, ok := blocks[0].(*notionapi.ParagraphBlock); !ok || p.ID != notionapi.BlockID(tt.block+"-1") || p.Paragraph.Text[0].PlainText != "Fixed rounding" {
+			t.Errorf("#%d: children(%s) = %+v", i, tt.block, blocks)
+		}
+		if got := f.childRequests[string(tt.block)]; got != tt.wantRequests {
+			t.Errorf("#%d: children(%s): got %d requests, want %d", i, tt.block, got, tt.wantRequests)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/nemca/taskgram/internal/render"
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+const (
+	emailDefaultSubject = `Standup {{date "Jan 2" .Start}} - {{date "Jan 2" .End}}`
+	emailDefaultTimeout = 30 * time.Second
+)
+
+// Email security modes
+const (
+	emailSecurityStartTLS = "starttls"
+	emailSecurityTLS      = "tls"
+	emailSecurityNone     = "none"
+)
+
+func init() {
+	Register("email", func(cfg *config.SinksConfig) (Sink, error) {
+		return NewEmailSink(cfg.Name, &cfg.Email)
+	})
+}
+
+// EmailSink sends the report as multipart text and HTML email over SMTP
+type EmailSink struct {
+	Cfg     *config.EmailConfig
+	Name    string
+	Subject *template.Template
+	// TLSConfig is used for TLS and STARTTLS connections.
+	// By default the server certificate is verified for the host.
+	TLSConfig *tls.Config
+}
+
+func NewEmailSink(name string, cfg *config.EmailConfig) (*EmailSink, error) {
+	if len(cfg.Host) < 1 || len(cfg.From) < 1 || len(cfg.To) < 1 {
+		return nil, fmt.Errorf("email_config.host, email_config.from and email_config.to are required")
+	}
+
+	sinkCfg := *cfg
+	if len(sinkCfg.Security) < 1 {
+		sinkCfg.Security = emailSecurityStartTLS
+	}
+	if sinkCfg.Port == 0 {
+		switch sinkCfg.Security {
+		case emailSecurityTLS:
+			sinkCfg.Port = 465
+		case emailSecurityStartTLS:
+			sinkCfg.Port = 587
+		case emailSecurityNone:
+			sinkCfg.Port = 25
+		}
+	}
+	switch sinkCfg.Security {
+	case emailSecurityStartTLS, emailSecurityTLS, emailSecurityNone:
+	default:
+		return nil, fmt.Errorf("unknown email_config.security %q", sinkCfg.Security)
+	}
+	if sinkCfg.Timeout == 0 {
+		sinkCfg.Timeout = emailDefaultTimeout
+	}
+	if len(sinkCfg.Subject) < 1 {
+		sinkCfg.Subject = emailDefaultSubject
+	}
+
+	subject, err := render.ParseTemplate("subject", sinkCfg.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("parse email_config.subject: %v", err)
+	}
+
+	return &EmailSink{
+		Cfg:     &sinkCfg,
+		Name:    name,
+		Subject: subject,
+	}, nil
+}
+
+// Publish implements Sink interface
+func (s *EmailSink) Publish(ctx context.Context, report *models.Report) error {
+	msg, err := s.message(report)
+	if err != nil {
+		return err
+	}
+
+	from, err := mail.ParseAddress(s.Cfg.From)
+	if err != nil {
+		return fmt.Errorf("parse email_config.from: %v", err)
+	}
+	var recipients []string
+	for _, addr := range append(append([]string{}, s.Cfg.To...), s.Cfg.Cc...) {
+		a, err := mail.ParseAddress(addr)
+		if err != nil {
+			return fmt.Errorf("parse recipient %q: %v", addr, err)
+		}
+		recipients = append(recipients, a.Address)
+	}
+
+	if err := s.send(ctx, from.Address, recipients, msg); err != nil {
+		return fmt.Errorf("send email: %v", err)
+	}
+	return nil
+}
+
+// Preview implements Sink interface
+func (s *EmailSink) Preview(w io.Writer, report *models.Report) error {
+	msg, err := s.message(report)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Send via %s:\n", net.JoinHostPort(s.Cfg.Host, strconv.Itoa(s.Cfg.Port)))
+	_, err = w.Write(msg)
+	return err
+}
+
+func (s *EmailSink) send(ctx context.Context, from string, to []string, msg []byte) error {
+	addr := net.JoinHostPort(s.Cfg.Host, strconv.Itoa(s.Cfg.Port))
+	tlsConfig := s.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{ServerName: s.Cfg.Host}
+	}
+
+	dialer := &net.Dialer{Timeout: s.Cfg.Timeout}
+	var conn net.Conn
+	var err error
+	if s.Cfg.Security == emailSecurityTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(s.Cfg.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, s.Cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if s.Cfg.Security == emailSecurityStartTLS {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("starttls: %v", err)
+		}
+	}
+	if len(s.Cfg.Username) > 0 {
+		if err := c.Auth(smtp.PlainAuth("", s.Cfg.Username, s.Cfg.Password, s.Cfg.Host)); err != nil {
+			return fmt.Errorf("auth: %v", err)
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("recipient %s: %v", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// message returns multipart/alternative message with text and HTML parts.
+func (s *EmailSink) message(report *models.Report) ([]byte, error) {
+	var subject bytes.Buffer
+	if err := s.Subject.Execute(&subject, report); err != nil {
+		return nil, fmt.Errorf("execute subject template: %v", err)
+	}
+
+	var text, html bytes.Buffer
+	if err := render.Text(&text, report); err != nil {
+		return nil, err
+	}
+	html.WriteString("<!DOCTYPE html>\n<html>\n<body>\n")
+	if err := render.HTML(&html, report); err != nil {
+		return nil, err
+	}
+	html.WriteString("</body>\n</html>\n")
+
+	var msg bytes.Buffer
+	mw := multipart.NewWriter(&msg)
+
+	headers := [][2]string{
+		{"From", s.Cfg.From},
+		{"To", strings.Join(s.Cfg.To, ", ")},
+		{"Cc", strings.Join(s.Cfg.Cc, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String()))},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", messageID(s.Cfg.From)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + mw.Boundary()},
+	}
+	for _, h := range headers {
+		if len(h[1]) > 0 {
+			fmt.Fprintf(&msg, "%s: %s\r\n", h[0], h[1])
+		}
+	}
+	msg.WriteString("\r\n")
+
+	for _, part := range []struct {
+		contentType string
+		body        []byte
+	}{
+		{contentType: "text/plain; charset=utf-8", body: text.Bytes()},
+		{contentType: "text/html; charset=utf-8", body: html.Bytes()},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(pw)
+		if _, err := qp.Write(part.body); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	return msg.Bytes(), nil
+}
+
+// messageID returns a unique Message-ID in the sender domain.
+func messageID(from string) string {
+	domain := "taskgram.local"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if i := strings.LastIndex(addr.Address, "@"); i >= 0 {
+			domain = addr.Address[i+1:]
+		}
+	}
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(b), domain)
+}
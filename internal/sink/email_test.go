@@ -0,0 +1,367 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nemca/taskgram/pkg/config"
+)
+
+// smtpSession is what the SMTP stub received
+type smtpSession struct {
+	TLS  bool
+	Auth string
+	From string
+	To   []string
+	Data []byte
+	Err  error
+}
+
+// smtpStub accepts one SMTP session. If tlsConfig is set,
+// STARTTLS is advertised unless the listener is TLS already.
+func smtpStub(t *testing.T, ln net.Listener, tlsConfig *tls.Config) <-chan smtpSession {
+	t.Helper()
+	sessions := make(chan smtpSession, 1)
+
+	go func() {
+		var s smtpSession
+		defer func() { sessions <- s }()
+
+		conn, err := ln.Accept()
+		if err != nil {
+			s.Err = err
+			return
+		}
+		defer conn.Close()
+		_, s.TLS = conn.(*tls.Conn)
+
+		tp := textproto.NewConn(conn)
+		reply := func(format string, args ...interface{}) {
+			if err := tp.PrintfLine(format, args...); err != nil && s.Err == nil {
+				s.Err = err
+			}
+		}
+
+		reply("220 localhost ESMTP stub")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				s.Err = err
+				return
+			}
+			cmd, arg := line, ""
+			if i := strings.IndexByte(line, ' '); i > 0 {
+				cmd, arg = line[:i], line[i+1:]
+			}
+
+			switch strings.ToUpper(cmd) {
+			case "EHLO":
+				reply("250-localhost")
+				if tlsConfig != nil && !s.TLS {
+					reply("250-STARTTLS")
+				}
+				reply("250 AUTH PLAIN")
+			case "STARTTLS":
+				if tlsConfig == nil || s.TLS {
+					reply("502 STARTTLS not supported")
+					continue
+				}
+				reply("220 Ready to start TLS")
+				tlsConn := tls.Server(conn, tlsConfig)
+				if err := tlsConn.Handshake(); err != nil {
+					s.Err = err
+					return
+				}
+				s.TLS = true
+				tp = textproto.NewConn(tlsConn)
+			case "AUTH":
+				b, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(arg, "PLAIN "))
+				s.Auth = string(b)
+				reply("235 Authenticated")
+			case "MAIL":
+				s.From = strings.TrimPrefix(arg, "FROM:")
+				reply("250 OK")
+			case "RCPT":
+				s.To = append(s.To, strings.TrimPrefix(arg, "TO:"))
+				reply("250 OK")
+			case "DATA":
+				reply("354 Go ahead")
+				s.Data, err = tp.ReadDotBytes()
+				if err != nil {
+					s.Err = err
+					return
+				}
+				reply("250 Queued")
+			case "QUIT":
+				reply("221 Bye")
+				return
+			default:
+				reply("502 Command not implemented")
+			}
+		}
+	}()
+
+	return sessions
+}
+
+// testTLSConfigs returns server and client TLS configs with a self-signed certificate for 127.0.0.1
+func testTLSConfigs(t *testing.T) (server, client *tls.Config) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	server = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	client = &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}
+	return server, client
+}
+
+func TestEmailPublish(t *testing.T) {
+	serverTLS, clientTLS := testTLSConfigs(t)
+
+	tests := []struct {
+		security string
+		username string
+		wantTLS  bool
+		wantAuth string
+	}{
+		{security: emailSecurityNone},
+		{security: emailSecurityNone, username: "john", wantAuth: "\x00john\x00secret"},
+		{security: emailSecurityStartTLS, username: "john", wantTLS: true, wantAuth: "\x00john\x00secret"},
+		{security: emailSecurityTLS, wantTLS: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.security+"/"+tt.username, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+			stubTLS := serverTLS
+			if tt.security == emailSecurityTLS {
+				ln = tls.NewListener(ln, serverTLS)
+			} else if tt.security == emailSecurityNone {
+				stubTLS = nil
+			}
+			sessions := smtpStub(t, ln, stubTLS)
+
+			s, err := NewEmailSink("email", &config.EmailConfig{
+				Host:     "127.0.0.1",
+				Port:     ln.Addr().(*net.TCPAddr).Port,
+				Security: tt.security,
+				Username: tt.username,
+				Password: "secret",
+				From:     "Taskgram <taskgram@example.com>",
+				To:       []string{"Team <team@example.com>", "lead@example.com"},
+				Cc:       []string{"Boss <boss@example.com>"},
+				Subject:  `Standup {{date "Jan 2" .End}} — {{join .Targets ", "}}`,
+				Timeout:  5 * time.Second,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.TLSConfig = clientTLS
+
+			if err := s.Publish(context.Background(), testReport()); err != nil {
+				t.Fatal(err)
+			}
+			session := <-sessions
+			if session.Err != nil {
+				t.Fatalf("SMTP stub: %v", session.Err)
+			}
+
+			if session.TLS != tt.wantTLS {
+				t.Errorf("TLS = %v, want %v", session.TLS, tt.wantTLS)
+			}
+			if session.Auth != tt.wantAuth {
+				t.Errorf("AUTH PLAIN = %q, want %q", session.Auth, tt.wantAuth)
+			}
+			if session.From != "<taskgram@example.com>" {
+				t.Errorf("MAIL FROM = %q, want <taskgram@example.com>", session.From)
+			}
+			wantTo := []string{"<team@example.com>", "<lead@example.com>", "<boss@example.com>"}
+			if !reflect.DeepEqual(session.To, wantTo) {
+				t.Errorf("RCPT TO = %q, want %q", session.To, wantTo)
+			}
+
+			checkEmailMessage(t, session.Data)
+		})
+	}
+}
+
+func checkEmailMessage(t *testing.T, data []byte) {
+	t.Helper()
+	msg, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(string(data))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != "Standup Jan 6 — jira" {
+		t.Errorf("Subject = %q, want %q", subject, "Standup Jan 6 — jira")
+	}
+	if to := msg.Header.Get("To"); to != "Team <team@example.com>, lead@example.com" {
+		t.Errorf("To = %q", to)
+	}
+	if cc := msg.Header.Get("Cc"); cc != "Boss <boss@example.com>" {
+		t.Errorf("Cc = %q", cc)
+	}
+	if id := msg.Header.Get("Message-ID"); !strings.HasSuffix(id, "@example.com>") {
+		t.Errorf("Message-ID = %q, want sender domain", id)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, want multipart/alternative", msg.Header.Get("Content-Type"))
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	var types []string
+	bodies := make(map[string]string)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		// multipart.Reader decodes quoted-printable parts
+		b, err := ioutil.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contentType := part.Header.Get("Content-Type")
+		types = append(types, contentType)
+		bodies[contentType] = string(b)
+	}
+
+	wantTypes := []string{"text/plain; charset=utf-8", "text/html; charset=utf-8"}
+	if !reflect.DeepEqual(types, wantTypes) {
+		t.Fatalf("parts = %q, want %q", types, wantTypes)
+	}
+	text, html := bodies[wantTypes[0]], bodies[wantTypes[1]]
+	if !strings.Contains(text, "ACME-1 Billing") || !strings.Contains(text, "Fixed rounding") || strings.Contains(text, "<") {
+		t.Errorf("text part:\n%s", text)
+	}
+	if !strings.HasPrefix(html, "<!DOCTYPE html>") || !strings.Contains(html, `href="https://jira.example.com/browse/ACME-1"`) || !strings.Contains(html, "Fixed rounding") {
+		t.Errorf("HTML part:\n%s", html)
+	}
+}
+
+func TestEmailStartTLSRequired(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// The server doesn't advertise STARTTLS
+	sessions := smtpStub(t, ln, nil)
+
+	s, err := NewEmailSink("email", &config.EmailConfig{
+		Host:    "127.0.0.1",
+		Port:    ln.Addr().(*net.TCPAddr).Port,
+		From:    "taskgram@example.com",
+		To:      []string{"team@example.com"},
+		Timeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Publish(context.Background(), testReport())
+	if err == nil || !strings.Contains(err.Error(), "starttls") {
+		t.Errorf("Publish() error = %v, want starttls error", err)
+	}
+	ln.Close()
+	if session := <-sessions; len(session.Data) > 0 {
+		t.Error("the message is sent without TLS")
+	}
+}
+
+func TestNewEmailSink(t *testing.T) {
+	tests := []struct {
+		cfg      config.EmailConfig
+		wantPort int
+		wantErr  bool
+	}{
+		{cfg: config.EmailConfig{Host: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com"}}, wantPort: 587},
+		{cfg: config.EmailConfig{Host: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com"}, Security: "tls"}, wantPort: 465},
+		{cfg: config.EmailConfig{Host: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com"}, Security: "none"}, wantPort: 25},
+		{cfg: config.EmailConfig{Host: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com"}, Security: "none", Port: 2525}, wantPort: 2525},
+		{cfg: config.EmailConfig{Host: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com"}, Security: "ssl"}, wantErr: true},
+		{cfg: config.EmailConfig{Host: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com"}, Subject: "{{.Missing"}, wantErr: true},
+		{cfg: config.EmailConfig{Host: "smtp.example.com", From: "a@example.com"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		s, err := NewEmailSink("email", &tt.cfg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewEmailSink(%+v) error = %v, want error %v", tt.cfg, err, tt.wantErr)
+			continue
+		}
+		if err == nil && s.Cfg.Port != tt.wantPort {
+			t.Errorf("NewEmailSink(%+v) port = %d, want %d", tt.cfg, s.Cfg.Port, tt.wantPort)
+		}
+	}
+}
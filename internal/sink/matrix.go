@@ -0,0 +1,205 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nemca/taskgram/internal/helpers"
+	"github.com/nemca/taskgram/internal/render"
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+const (
+	matrixDefaultMaxAttempts = 3
+	matrixRetryDelay         = time.Second
+)
+
+func init() {
+	Register("matrix", func(cfg *config.SinksConfig) (Sink, error) {
+		return NewMatrixSink(cfg.Name, &cfg.Matrix)
+	})
+}
+
+// MatrixSink sends the report as m.room.message to a Matrix room
+type MatrixSink struct {
+	Client *http.Client
+	Cfg    *config.MatrixConfig
+	Name   string
+}
+
+type matrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format"`
+	FormattedBody string `json:"formatted_body"`
+}
+
+type matrixError struct {
+	ErrCode      string `json:"errcode"`
+	Error        string `json:"error"`
+	RetryAfterMs int64  `json:"retry_after_ms"`
+}
+
+func NewMatrixSink(name string, cfg *config.MatrixConfig) (*MatrixSink, error) {
+	if len(cfg.HomeserverURL) < 1 || len(cfg.AccessToken) < 1 || len(cfg.RoomID) < 1 {
+		return nil, fmt.Errorf("matrix_config.homeserverURL, matrix_config.accessToken and matrix_config.roomID are required")
+	}
+
+	sinkCfg := *cfg
+	sinkCfg.HomeserverURL = strings.TrimSuffix(sinkCfg.HomeserverURL, "/")
+	if sinkCfg.MaxAttempts < 1 {
+		sinkCfg.MaxAttempts = matrixDefaultMaxAttempts
+	}
+
+	return &MatrixSink{
+		Client: &http.Client{},
+		Cfg:    &sinkCfg,
+		Name:   name,
+	}, nil
+}
+
+// Publish implements Sink interface
+func (s *MatrixSink) Publish(ctx context.Context, report *models.Report) error {
+	msg, err := s.message(report)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	// The same transaction ID is used for all attempts,
+	// so the homeserver doesn't post the message twice.
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		s.Cfg.HomeserverURL, url.PathEscape(s.Cfg.RoomID), matrixTxnID(s.Cfg.RoomID, body))
+
+	for attempt := 1; ; attempt++ {
+		retryAfter, err := s.send(ctx, endpoint, body)
+		if err == nil {
+			return nil
+		}
+		if retryAfter < 0 || attempt >= s.Cfg.MaxAttempts {
+			return fmt.Errorf("send Matrix message: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryAfter):
+		}
+	}
+}
+
+// Preview implements Sink interface
+func (s *MatrixSink) Preview(w io.Writer, report *models.Report) error {
+	msg, err := s.message(report)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Room %s:\n", s.Cfg.RoomID)
+	return previewJSON(w, msg)
+}
+
+// send puts the message and returns delay before the next attempt.
+// Negative delay means the error is permanent.
+func (s *MatrixSink) send(ctx context.Context, endpoint string, body []byte) (time.Duration, error) {
+	ctx, cancel := helpers.WithTimeout(ctx, s.Cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.Cfg.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return -1, err
+		}
+		return matrixRetryDelay, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return 0, nil
+	}
+
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	var mErr matrixError
+	if json.Unmarshal(b, &mErr) != nil || len(mErr.ErrCode) < 1 {
+		mErr.Error = strings.TrimSpace(string(b))
+	}
+	err = fmt.Errorf("unexpected status %s: %s %s", resp.Status, mErr.ErrCode, mErr.Error)
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		if mErr.RetryAfterMs > 0 {
+			return time.Duration(mErr.RetryAfterMs) * time.Millisecond, err
+		}
+		if sec, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil {
+			return time.Duration(sec) * time.Second, err
+		}
+		return matrixRetryDelay, err
+	case resp.StatusCode >= 500:
+		return matrixRetryDelay, err
+	}
+	return -1, err
+}
+
+func (s *MatrixSink) message(report *models.Report) (matrixMessage, error) {
+	var text, html bytes.Buffer
+	if err := render.Text(&text, report); err != nil {
+		return matrixMessage{}, err
+	}
+	if err := render.HTML(&html, report); err != nil {
+		return matrixMessage{}, err
+	}
+
+	return matrixMessage{
+		MsgType:       "m.text",
+		Body:          strings.TrimSpace(text.String()),
+		Format:        "org.matrix.custom.html",
+		FormattedBody: strings.TrimSpace(html.String()),
+	}, nil
+}
+
+// matrixTxnID returns a transaction ID derived from the room and the message,
+// so re-running taskgram with the same report doesn't duplicate the message.
+func matrixTxnID(roomID string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(roomID))
+	h.Write([]byte{0})
+	h.Write(body)
+	return "taskgram-" + hex.EncodeToString(h.Sum(nil))[:32]
+}
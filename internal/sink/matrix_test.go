@@ -0,0 +1,212 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nemca/taskgram/pkg/config"
+)
+
+// matrixRequest is a request received by the fake homeserver
+type matrixRequest struct {
+	Method        string
+	Path          string
+	Authorization string
+	Body          string
+}
+
+// fakeHomeserver replies with the responses in order, the last one is repeated
+func fakeHomeserver(t *testing.T, responses ...func(w http.ResponseWriter)) (*httptest.Server, func() []matrixRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []matrixRequest
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, matrixRequest{
+			Method:        r.Method,
+			Path:          r.URL.Path,
+			Authorization: r.Header.Get("Authorization"),
+			Body:          string(b),
+		})
+		i := len(requests) - 1
+		mu.Unlock()
+
+		if i >= len(responses) {
+			i = len(responses) - 1
+		}
+		responses[i](w)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, func() []matrixRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]matrixRequest{}, requests...)
+	}
+}
+
+func matrixReply(status int, header map[string]string, body string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		for k, v := range header {
+			w.Header().Set(k, v)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}
+}
+
+func newTestMatrixSink(t *testing.T, url, roomID string, maxAttempts int) *MatrixSink {
+	t.Helper()
+	s, err := NewMatrixSink("matrix", &config.MatrixConfig{
+		HomeserverURL: url + "/",
+		AccessToken:   "secret",
+		RoomID:        roomID,
+		MaxAttempts:   maxAttempts,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestMatrixPublishRetriesRateLimit(t *testing.T) {
+	srv, requests := fakeHomeserver(t,
+		matrixReply(http.StatusTooManyRequests, nil, `{"errcode":"M_LIMIT_EXCEEDED","error":"Too many requests","retry_after_ms":50}`),
+		matrixReply(http.StatusTooManyRequests, map[string]string{"Retry-After": "0"}, `rate limited`),
+		matrixReply(http.StatusOK, nil, `{"event_id":"$1"}`),
+	)
+	s := newTestMatrixSink(t, srv.URL, "!abc:example.org", 3)
+
+	start := time.Now()
+	if err := s.Publish(context.Background(), testReport()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Publish() took %v, want retry after 50ms", elapsed)
+	}
+
+	got := requests()
+	if len(got) != 3 {
+		t.Fatalf("got %d requests, want 3", len(got))
+	}
+	prefix := "/_matrix/client/v3/rooms/!abc:example.org/send/m.room.message/taskgram-"
+	for i, r := range got {
+		if r.Method != http.MethodPut || !strings.HasPrefix(r.Path, prefix) {
+			t.Errorf("request %d = %s %s, want PUT %s<txnID>", i, r.Method, r.Path, prefix)
+		}
+		if r.Authorization != "Bearer secret" {
+			t.Errorf("request %d Authorization = %q, want Bearer secret", i, r.Authorization)
+		}
+		// Retries reuse the transaction ID and the body
+		if r.Path != got[0].Path || r.Body != got[0].Body {
+			t.Errorf("request %d differs from the first one", i)
+		}
+	}
+
+	var msg matrixMessage
+	if err := json.Unmarshal([]byte(got[0].Body), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.MsgType != "m.text" || msg.Format != "org.matrix.custom.html" {
+		t.Errorf("message = %+v, want m.text with HTML", msg)
+	}
+	if !strings.Contains(msg.Body, "Fixed rounding") || !strings.Contains(msg.FormattedBody, `href="https://jira.example.com/browse/ACME-1"`) {
+		t.Errorf("message misses the report: %+v", msg)
+	}
+}
+
+func TestMatrixPublishErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		reply        func(w http.ResponseWriter)
+		wantRequests int
+		want         string
+	}{
+		{
+			name:         "permanent",
+			reply:        matrixReply(http.StatusForbidden, nil, `{"errcode":"M_FORBIDDEN","error":"not in the room"}`),
+			wantRequests: 1,
+			want:         "403 Forbidden: M_FORBIDDEN not in the room",
+		},
+		{
+			name:         "attempts exhausted",
+			reply:        matrixReply(http.StatusTooManyRequests, nil, `{"errcode":"M_LIMIT_EXCEEDED","error":"slow down","retry_after_ms":1}`),
+			wantRequests: 2,
+			want:         "429 Too Many Requests: M_LIMIT_EXCEEDED slow down",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := fakeHomeserver(t, tt.reply)
+			s := newTestMatrixSink(t, srv.URL, "!abc:example.org", 2)
+
+			err := s.Publish(context.Background(), testReport())
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Publish() error = %v, want %q", err, tt.want)
+			}
+			if n := len(requests()); n != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", n, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestMatrixTxnIDIsIdempotent(t *testing.T) {
+	srv, requests := fakeHomeserver(t, matrixReply(http.StatusOK, nil, `{"event_id":"$1"}`))
+	s := newTestMatrixSink(t, srv.URL, "!abc:example.org", 1)
+	other := newTestMatrixSink(t, srv.URL, "!def:example.org", 1)
+
+	changed := testReport()
+	changed.Today.Tasks[0].Notes = append(changed.Today.Tasks[0].Notes, "Deploy")
+
+	for _, p := range []func() error{
+		func() error { return s.Publish(context.Background(), testReport()) },
+		func() error { return s.Publish(context.Background(), testReport()) },
+		func() error { return s.Publish(context.Background(), changed) },
+		func() error { return other.Publish(context.Background(), testReport()) },
+	} {
+		if err := p(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := requests()
+	txnID := func(i int) string { return got[i].Path[strings.LastIndex(got[i].Path, "/")+1:] }
+	if txnID(0) != txnID(1) {
+		t.Errorf("the same report is sent with transaction IDs %q and %q", txnID(0), txnID(1))
+	}
+	if txnID(0) == txnID(2) {
+		t.Error("the changed report is sent with the same transaction ID")
+	}
+	if txnID(0) == txnID(3) {
+		t.Error("the report to another room is sent with the same transaction ID")
+	}
+}
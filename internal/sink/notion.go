@@ -0,0 +1,354 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jomei/notionapi"
+	"github.com/nemca/taskgram/internal/helpers"
+	"github.com/nemca/taskgram/internal/repository"
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+const (
+	notionDefaultTitleProperty = "Name"
+	notionDefaultTitlePrefix   = "Standup"
+	// notionMaxChildren is the maximum number of blocks in one children array
+	notionMaxChildren = 100
+	// notionMaxBlocks is the maximum number of blocks in one request including nested ones
+	notionMaxBlocks = 1000
+	// notionMaxTextLen is the maximum length of rich text content
+	notionMaxTextLen = 2000
+)
+
+func init() {
+	Register("notion", func(cfg *config.SinksConfig) (Sink, error) {
+		return NewNotionSink(cfg.Name, &cfg.Notion)
+	})
+}
+
+// NotionSink writes the report as a page to Notion database, one page per date
+type NotionSink struct {
+	Client *notionapi.Client
+	Cfg    *config.NotionSinkConfig
+	Name   string
+}
+
+func NewNotionSink(name string, cfg *config.NotionSinkConfig) (*NotionSink, error) {
+	if len(cfg.DatabaseID) < 1 {
+		return nil, fmt.Errorf("notion_config.databaseID is required")
+	}
+	client, err := repository.NewNotionClient(cfg.APIKey, cfg.BaseURL, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	sinkCfg := *cfg
+	if len(sinkCfg.TitleProperty) < 1 {
+		sinkCfg.TitleProperty = notionDefaultTitleProperty
+	}
+	if len(sinkCfg.TitlePrefix) < 1 {
+		sinkCfg.TitlePrefix = notionDefaultTitlePrefix
+	}
+
+	return &NotionSink{
+		Client: client,
+		Cfg:    &sinkCfg,
+		Name:   name,
+	}, nil
+}
+
+// Publish implements Sink interface.
+// If the page for the report date already exists, its content is replaced.
+func (s *NotionSink) Publish(ctx context.Context, report *models.Report) error {
+	title := s.title(report)
+	blocks := notionBlocks(report)
+
+	page, err := s.FindPage(ctx, title)
+	if err != nil {
+		return err
+	}
+
+	if page == nil {
+		// Blocks with too many children are appended later by parts
+		first, rest := nextChunk(blocks)
+		if _, overflow := splitChildren(first); hasBlocks(overflow) {
+			first, rest = nil, blocks
+		}
+		page, err = s.createPage(ctx, title, report.End, first)
+		if err != nil {
+			return err
+		}
+		blocks = rest
+	} else if err := s.clearPage(ctx, notionapi.BlockID(page.ID)); err != nil {
+		return err
+	}
+
+	return s.appendBlocks(ctx, notionapi.BlockID(page.ID), blocks)
+}
+
+// Preview implements Sink interface
+func (s *NotionSink) Preview(w io.Writer, report *models.Report) error {
+	fmt.Fprintf(w, "Create or update page %q in database %s:\n", s.title(report), s.Cfg.DatabaseID)
+	return previewJSON(w, notionBlocks(report))
+}
+
+// FindPage returns the page with the title from the database or nil if it's not found.
+func (s *NotionSink) FindPage(ctx context.Context, title string) (*notionapi.Page, error) {
+	ctx, cancel := helpers.WithTimeout(ctx, s.Cfg.Timeout)
+	defer cancel()
+
+	resp, err := s.Client.Database.Query(ctx, notionapi.DatabaseID(s.Cfg.DatabaseID), &notionapi.DatabaseQueryRequest{
+		PropertyFilter: &notionapi.PropertyFilter{
+			Property: s.Cfg.TitleProperty,
+			Text:     &notionapi.TextFilterCondition{Equals: title},
+		},
+		PageSize: 1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("find Notion page %q: %v", title, err)
+	}
+	if len(resp.Results) < 1 {
+		return nil, nil
+	}
+	return &resp.Results[0], nil
+}
+
+func (s *NotionSink) createPage(ctx context.Context, title string, date time.Time, children []notionapi.Block) (*notionapi.Page, error) {
+	ctx, cancel := helpers.WithTimeout(ctx, s.Cfg.Timeout)
+	defer cancel()
+
+	properties := notionapi.Properties{
+		s.Cfg.TitleProperty: notionapi.TitleProperty{
+			Title: []notionapi.RichText{{Text: notionapi.Text{Content: title}}},
+		},
+	}
+	if len(s.Cfg.DateProperty) > 0 {
+		year, month, day := date.Date()
+		start := notionapi.Date(time.Date(year, month, day, 0, 0, 0, 0, date.Location()))
+		properties[s.Cfg.DateProperty] = notionapi.DateProperty{
+			Date: notionapi.DateObject{Start: &start},
+		}
+	}
+
+	page, err := s.Client.Page.Create(ctx, &notionapi.PageCreateRequest{
+		Parent: notionapi.Parent{
+			Type:       notionapi.ParentTypeDatabaseID,
+			DatabaseID: notionapi.DatabaseID(s.Cfg.DatabaseID),
+		},
+		Properties: properties,
+		Children:   children,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create Notion page %q: %v", title, err)
+	}
+	return page, nil
+}
+
+// clearPage deletes all blocks of the page.
+func (s *NotionSink) clearPage(ctx context.Context, pageID notionapi.BlockID) error {
+	var ids []notionapi.BlockID
+	var cursor notionapi.Cursor
+
+	for hasMore := true; hasMore; {
+		reqCtx, cancel := helpers.WithTimeout(ctx, s.Cfg.Timeout)
+		resp, err := s.Client.Block.GetChildren(reqCtx, pageID, &notionapi.Pagination{StartCursor: cursor, PageSize: notionMaxChildren})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("get Notion page content: %v", err)
+		}
+		for _, block := range resp.Results {
+			ids = append(ids, block.GetID())
+		}
+		hasMore = resp.HasMore
+		cursor = notionapi.Cursor(resp.NextCursor)
+	}
+
+	for _, id := range ids {
+		reqCtx, cancel := helpers.WithTimeout(ctx, s.Cfg.Timeout)
+		_, err := s.Client.Block.Delete(reqCtx, id)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("delete Notion block: %v", err)
+		}
+	}
+	return nil
+}
+
+// appendBlocks appends blocks to the parent by chunks.
+// List items with more nested children than allowed in a request are sent
+// with the first children, the rest is appended to the created items.
+func (s *NotionSink) appendBlocks(ctx context.Context, parentID notionapi.BlockID, blocks []notionapi.Block) error {
+	for len(blocks) > 0 {
+		var chunk []notionapi.Block
+		chunk, blocks = nextChunk(blocks)
+		chunk, overflow := splitChildren(chunk)
+
+		reqCtx, cancel := helpers.WithTimeout(ctx, s.Cfg.Timeout)
+		resp, err := s.Client.Block.AppendChildren(reqCtx, parentID, &notionapi.AppendBlockChildrenRequest{Children: chunk})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("append Notion blocks: %v", err)
+		}
+
+		for i, children := range overflow {
+			if len(children) < 1 {
+				continue
+			}
+			if i >= len(resp.Results) {
+				return fmt.Errorf("append Notion blocks: %d blocks created, expected %d", len(resp.Results), len(chunk))
+			}
+			if err := s.appendBlocks(ctx, resp.Results[i].GetID(), children); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// nextChunk returns the first blocks which fit in one request and the rest
+func nextChunk(blocks []notionapi.Block) (chunk, rest []notionapi.Block) {
+	n, total := 0, 0
+	for ; n < len(blocks) && n < notionMaxChildren; n++ {
+		size := 1
+		if item, ok := blocks[n].(*notionapi.BulletedListItemBlock); ok {
+			children := len(item.BulletedListItem.Children)
+			if children > notionMaxChildren {
+				children = notionMaxChildren
+			}
+			size += children
+		}
+		if n > 0 && total+size > notionMaxBlocks {
+			break
+		}
+		total += size
+	}
+	return blocks[:n], blocks[n:]
+}
+
+// splitChildren returns list items with at most notionMaxChildren nested children
+// and the rest of their children by the item index
+func splitChildren(blocks []notionapi.Block) ([]notionapi.Block, [][]notionapi.Block) {
+	result := make([]notionapi.Block, len(blocks))
+	overflow := make([][]notionapi.Block, len(blocks))
+	for i, block := range blocks {
+		result[i] = block
+		item, ok := block.(*notionapi.BulletedListItemBlock)
+		if !ok || len(item.BulletedListItem.Children) <= notionMaxChildren {
+			continue
+		}
+		head := *item
+		head.BulletedListItem.Children = item.BulletedListItem.Children[:notionMaxChildren]
+		result[i] = &head
+		overflow[i] = item.BulletedListItem.Children[notionMaxChildren:]
+	}
+	return result, overflow
+}
+
+func hasBlocks(lists [][]notionapi.Block) bool {
+	for _, list := range lists {
+		if len(list) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *NotionSink) title(report *models.Report) string {
+	return s.Cfg.TitlePrefix + " " + report.End.Format("2006-01-02")
+}
+
+// notionBlocks returns heading and bulleted list blocks for YESTERDAY and TODAY.
+// Tasks link back to their pages, notes and meetings are nested items.
+func notionBlocks(report *models.Report) []notionapi.Block {
+	var blocks []notionapi.Block
+
+	for _, s := range []struct {
+		title string
+		items models.Items
+	}{
+		{title: "YESTERDAY", items: report.Done},
+		{title: "TODAY", items: report.Today},
+	} {
+		if s.items.Empty() {
+			continue
+		}
+		blocks = append(blocks, &notionapi.Heading2Block{
+			BasicBlock: notionapi.BasicBlock{Object: notionapi.ObjectTypeBlock, Type: notionapi.BlockTypeHeading2},
+			Heading2:   notionapi.Heading{Text: notionText(s.title, "")},
+		})
+
+		for _, t := range s.items.Tasks {
+			if len(t.Notes) < 1 {
+				continue
+			}
+			text := notionText(t.Title, t.URL)
+			if len(t.Projects) > 0 {
+				var tags []string
+				for _, p := range t.Projects {
+					tags = append(tags, "#"+strings.ToLower(p))
+				}
+				text = append(text, notionText(" "+strings.Join(tags, " "), "")...)
+			}
+			blocks = append(blocks, notionListItem(text, t.Notes))
+		}
+
+		if s.items.Events.EventsLen() > 0 {
+			var summaries []string
+			for _, e := range s.items.Events {
+				if len(e.Summary) > 0 {
+					summaries = append(summaries, e.Summary)
+				}
+			}
+			blocks = append(blocks, notionListItem(notionText("Meetings", ""), summaries))
+		}
+	}
+
+	return blocks
+}
+
+func notionListItem(text []notionapi.RichText, children []string) *notionapi.BulletedListItemBlock {
+	item := &notionapi.BulletedListItemBlock{
+		BasicBlock:       notionapi.BasicBlock{Object: notionapi.ObjectTypeBlock, Type: notionapi.BlockTypeBulletedListItem},
+		BulletedListItem: notionapi.ListItem{Text: text},
+	}
+	for _, child := range children {
+		item.BulletedListItem.Children = append(item.BulletedListItem.Children, &notionapi.BulletedListItemBlock{
+			BasicBlock:       notionapi.BasicBlock{Object: notionapi.ObjectTypeBlock, Type: notionapi.BlockTypeBulletedListItem},
+			BulletedListItem: notionapi.ListItem{Text: notionText(child, "")},
+		})
+	}
+	return item
+}
+
+// notionText returns rich text with optional link, truncated to Notion limit.
+func notionText(content, link string) []notionapi.RichText {
+	if runes := []rune(content); len(runes) > notionMaxTextLen {
+		content = string(runes[:notionMaxTextLen])
+	}
+	text := notionapi.Text{Content: content}
+	if len(link) > 0 {
+		text.Link = &notionapi.Link{Url: link}
+	}
+	return []notionapi.RichText{{Type: notionapi.ObjectTypeText, Text: text}}
+}
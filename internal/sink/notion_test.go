@@ -0,0 +1,302 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jomei/notionapi"
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+// notionRequest is a request received by fakeNotion
+type notionRequest struct {
+	Method string
+	Path   string
+	Body   map[string]interface{}
+}
+
+// fakeNotion serves Notion API endpoints used by the sink.
+// Appended blocks get IDs "<parent>-<index>".
+type fakeNotion struct {
+	mu       sync.Mutex
+	requests []notionRequest
+	// pages found by the database query
+	pages []string
+	// children of existing blocks
+	children map[string][]string
+}
+
+func (f *fakeNotion) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		http.Error(w, `{"object":"error","status":401,"code":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	req := notionRequest{Method: r.Method, Path: r.URL.Path}
+	if r.ContentLength != 0 && r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		if err := json.NewDecoder(r.Body).Decode(&req.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	f.mu.Unlock()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodPost && len(parts) == 4 && parts[1] == "databases" && parts[3] == "query":
+		var results []string
+		for _, id := range f.pages {
+			results = append(results, fmt.Sprintf(`{"object":"page","id":%q,"properties":{}}`, id))
+		}
+		fmt.Fprintf(w, `{"object":"list","results":[%s],"has_more":false}`, strings.Join(results, ","))
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/pages":
+		fmt.Fprint(w, `{"object":"page","id":"page-1","properties":{}}`)
+	case r.Method == http.MethodGet && len(parts) == 4 && parts[1] == "blocks" && parts[3] == "children":
+		fmt.Fprintf(w, `{"object":"list","results":[%s],"has_more":false}`, dividers(f.children[parts[2]]))
+	case r.Method == http.MethodPatch && len(parts) == 4 && parts[1] == "blocks" && parts[3] == "children":
+		children, _ := req.Body["children"].([]interface{})
+		var ids []string
+		for i := range children {
+			ids = append(ids, fmt.Sprintf("%s-%d", parts[2], i))
+		}
+		fmt.Fprintf(w, `{"object":"list","results":[%s]}`, dividers(ids))
+	case r.Method == http.MethodDelete && len(parts) == 3 && parts[1] == "blocks":
+		fmt.Fprint(w, dividers([]string{parts[2]}))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func dividers(ids []string) string {
+	var blocks []string
+	for _, id := range ids {
+		blocks = append(blocks, fmt.Sprintf(`{"object":"block","id":%q,"type":"divider","divider":{}}`, id))
+	}
+	return strings.Join(blocks, ",")
+}
+
+// children returns children of the block in the request body
+func children(v interface{}) []interface{} {
+	block, _ := v.(map[string]interface{})
+	if children, ok := block["children"].([]interface{}); ok {
+		return children
+	}
+	item, _ := block["bulleted_list_item"].(map[string]interface{})
+	children, _ := item["children"].([]interface{})
+	return children
+}
+
+func newTestNotionSink(t *testing.T, f *fakeNotion) *NotionSink {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	s, err := NewNotionSink("notion", &config.NotionSinkConfig{
+		APIKey:       "secret",
+		BaseURL:      srv.URL,
+		DatabaseID:   "db-1",
+		DateProperty: "Date",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestNotionPublishCreatesPage(t *testing.T) {
+	f := &fakeNotion{}
+	s := newTestNotionSink(t, f)
+
+	report := testReport()
+	var notes []string
+	for i := 0; i < 150; i++ {
+		notes = append(notes, fmt.Sprintf("note %d", i))
+	}
+	report.Done.Tasks[0].Notes = notes
+	if err := s.Publish(context.Background(), report); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, r := range f.requests {
+		got = append(got, r.Method+" "+r.Path)
+	}
+	want := []string{
+		"POST /v1/databases/db-1/query",
+		"POST /v1/pages",
+		"PATCH /v1/blocks/page-1/children",
+		// The rest of notes is appended to the created task item
+		"PATCH /v1/blocks/page-1-1/children",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("requests = %q, want %q", got, want)
+	}
+
+	filter, _ := f.requests[0].Body["filter"].(map[string]interface{})
+	if filter["property"] != "Name" || !strings.Contains(fmt.Sprint(filter), "equals:Standup 2026-01-06") {
+		t.Errorf("query filter = %v, want Name equals Standup 2026-01-06", filter)
+	}
+
+	page := f.requests[1].Body
+	if parent, _ := page["parent"].(map[string]interface{}); parent["database_id"] != "db-1" {
+		t.Errorf("page parent = %v, want database db-1", page["parent"])
+	}
+	properties := fmt.Sprint(page["properties"])
+	if !strings.Contains(properties, "content:Standup 2026-01-06") || !strings.Contains(properties, "start:2026-01-06") {
+		t.Errorf("page properties = %s, want title and date of the report", properties)
+	}
+	// The page is created empty because the task has too many notes
+	if n := len(children(page)); n > 0 {
+		t.Errorf("page is created with %d blocks, want none", n)
+	}
+
+	blocks := children(f.requests[2].Body)
+	// YESTERDAY, task, meetings, TODAY, task
+	if len(blocks) != 5 {
+		t.Fatalf("appended %d blocks, want 5", len(blocks))
+	}
+	if n := len(children(blocks[1])); n != notionMaxChildren {
+		t.Errorf("task item has %d notes, want %d", n, notionMaxChildren)
+	}
+	rest := children(f.requests[3].Body)
+	if len(rest) != 50 || !strings.Contains(fmt.Sprint(rest[0]), "note 100") {
+		t.Errorf("appended %d notes to the task item, want 50 starting from note 100", len(rest))
+	}
+}
+
+func TestNotionPublishReplacesPage(t *testing.T) {
+	f := &fakeNotion{
+		pages:    []string{"page-1"},
+		children: map[string][]string{"page-1": {"old-1", "old-2"}},
+	}
+	s := newTestNotionSink(t, f)
+
+	if err := s.Publish(context.Background(), testReport()); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, r := range f.requests {
+		got = append(got, r.Method+" "+r.Path)
+	}
+	want := []string{
+		"POST /v1/databases/db-1/query",
+		"GET /v1/blocks/page-1/children",
+		"DELETE /v1/blocks/old-1",
+		"DELETE /v1/blocks/old-2",
+		"PATCH /v1/blocks/page-1/children",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("requests = %q, want %q", got, want)
+	}
+	if n := len(children(f.requests[4].Body)); n != 5 {
+		t.Errorf("appended %d blocks, want 5", n)
+	}
+}
+
+func listItem(n int) *notionapi.BulletedListItemBlock {
+	var notes []string
+	for i := 0; i < n; i++ {
+		notes = append(notes, fmt.Sprintf("note %d", i))
+	}
+	return notionListItem(notionText("task", ""), notes)
+}
+
+func TestNextChunk(t *testing.T) {
+	blocks := func(n, children int) []notionapi.Block {
+		var result []notionapi.Block
+		for i := 0; i < n; i++ {
+			result = append(result, listItem(children))
+		}
+		return result
+	}
+
+	tests := []struct {
+		name   string
+		blocks []notionapi.Block
+		want   []int
+	}{
+		{name: "empty"},
+		{name: "fits", blocks: blocks(3, 2), want: []int{3}},
+		{name: "top-level limit", blocks: blocks(250, 0), want: []int{100, 100, 50}},
+		{name: "nested limit", blocks: blocks(20, 99), want: []int{10, 10}},
+		// Children over notionMaxChildren are appended separately and not counted
+		{name: "large children", blocks: blocks(10, 500), want: []int{9, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			for rest := tt.blocks; len(rest) > 0; {
+				var chunk []notionapi.Block
+				chunk, rest = nextChunk(rest)
+				if len(chunk) < 1 {
+					t.Fatal("nextChunk() returned empty chunk")
+				}
+				got = append(got, len(chunk))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chunks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitChildren(t *testing.T) {
+	heading := &notionapi.Heading2Block{}
+	blocks := []notionapi.Block{heading, listItem(250), listItem(100)}
+
+	result, overflow := splitChildren(blocks)
+
+	if result[0] != heading || result[2] != blocks[2] {
+		t.Error("splitChildren() changed blocks without extra children")
+	}
+	if n := len(result[1].(*notionapi.BulletedListItemBlock).BulletedListItem.Children); n != notionMaxChildren {
+		t.Errorf("item has %d children, want %d", n, notionMaxChildren)
+	}
+	if n := len(blocks[1].(*notionapi.BulletedListItemBlock).BulletedListItem.Children); n != 250 {
+		t.Errorf("original item has %d children, want 250", n)
+	}
+	if len(overflow[0]) > 0 || len(overflow[1]) != 150 || len(overflow[2]) > 0 {
+		t.Errorf("overflow sizes = %d, %d, %d, want 0, 150, 0", len(overflow[0]), len(overflow[1]), len(overflow[2]))
+	}
+	if !hasBlocks(overflow) || hasBlocks(make([][]notionapi.Block, 3)) {
+		t.Error("hasBlocks() is wrong")
+	}
+}
+
+func TestNotionBlocksSkipEmptySections(t *testing.T) {
+	report := &models.Report{Today: models.Items{Tasks: models.Tasks{{Title: "Plan", Notes: []string{"Write tests"}}}}}
+	blocks := notionBlocks(report)
+	if len(blocks) != 2 {
+		t.Fatalf("got %d blocks, want heading and task", len(blocks))
+	}
+	if heading, ok := blocks[0].(*notionapi.Heading2Block); !ok || heading.Heading2.Text[0].Text.Content != "TODAY" {
+		t.Errorf("first block = %+v, want TODAY heading", blocks[0])
+	}
+}
@@ -0,0 +1,63 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sink implements publishing of the report to external services.
+package sink
+
+import (
+	"context"
+	"io"
+
+	"github.com/nemca/taskgram/internal/registry"
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+// Sink publishes the report
+type Sink interface {
+	// Publish sends the report.
+	Publish(ctx context.Context, report *models.Report) error
+	// Preview writes what would be sent by Publish without sending it.
+	Preview(w io.Writer, report *models.Report) error
+}
+
+// Factory creates a Sink from the sink config.
+type Factory func(cfg *config.SinksConfig) (Sink, error)
+
+var factories = registry.New[Factory]("sink")
+
+// Register makes a sink factory available by the sink type.
+// If Register is called twice with the same type or if factory is nil, it panics.
+func Register(sinkType string, factory Factory) {
+	if factory == nil {
+		panic("sink: Register factory is nil")
+	}
+	factories.Register(sinkType, factory)
+}
+
+// New creates a sink by its type.
+func New(cfg *config.SinksConfig) (Sink, error) {
+	factory, err := factories.Get(cfg.Type)
+	if err != nil {
+		return nil, err
+	}
+	return factory(cfg)
+}
+
+// Types returns a sorted list of the registered sink types.
+func Types() []string {
+	return factories.Types()
+}
@@ -0,0 +1,46 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"time"
+
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+// testReport returns a report with done and today tasks and events
+func testReport() *models.Report {
+	end := time.Date(2026, 1, 6, 9, 30, 0, 0, time.UTC)
+	return &models.Report{
+		Start:   end.Add(-24 * time.Hour),
+		End:     end,
+		Targets: []string{"jira"},
+		Done: models.Items{
+			Tasks: models.Tasks{
+				{Title: "ACME-1 Billing", URL: "https://jira.example.com/browse/ACME-1", Notes: []string{"Fixed rounding"}},
+			},
+			Events: models.Events{
+				{Summary: "Standup", Start: end.Add(-24 * time.Hour), End: end.Add(-24*time.Hour + 15*time.Minute)},
+			},
+		},
+		Today: models.Items{
+			Tasks: models.Tasks{
+				{Title: "ACME-2 Invoices", Notes: []string{"Write tests"}},
+			},
+		},
+	}
+}
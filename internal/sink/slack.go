@@ -0,0 +1,223 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nemca/taskgram/internal/helpers"
+	"github.com/nemca/taskgram/internal/render"
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+const (
+	slackDefaultBaseURL = "https://slack.com/api"
+	// slackMaxSectionLen is the maximum length of section block text
+	slackMaxSectionLen = 3000
+	// slackMaxBlocks is the maximum number of blocks in a message
+	slackMaxBlocks = 50
+)
+
+func init() {
+	Register("slack", func(cfg *config.SinksConfig) (Sink, error) {
+		return NewSlackSink(cfg.Name, &cfg.Slack)
+	})
+}
+
+// SlackSink posts the report as Block Kit message via incoming webhook or chat.postMessage
+type SlackSink struct {
+	Client *http.Client
+	Cfg    *config.SlackConfig
+	Name   string
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type string     `json:"type"`
+	Text *slackText `json:"text,omitempty"`
+}
+
+type slackMessage struct {
+	Channel  string       `json:"channel,omitempty"`
+	ThreadTS string       `json:"thread_ts,omitempty"`
+	Text     string       `json:"text"`
+	Blocks   []slackBlock `json:"blocks,omitempty"`
+}
+
+type slackResponse struct {
+	OK       bool   `json:"ok"`
+	Error    string `json:"error"`
+	Messages []struct {
+		TS   string `json:"ts"`
+		Text string `json:"text"`
+	} `json:"messages"`
+}
+
+func NewSlackSink(name string, cfg *config.SlackConfig) (*SlackSink, error) {
+	if len(cfg.WebhookURL) < 1 && (len(cfg.Token) < 1 || len(cfg.Channel) < 1) {
+		return nil, fmt.Errorf("slack_config.webhookURL or slack_config.token and slack_config.channel are required")
+	}
+	baseURL := strings.TrimSuffix(cfg.BaseURL, "/")
+	if len(baseURL) < 1 {
+		baseURL = slackDefaultBaseURL
+	}
+
+	return &SlackSink{
+		Client: &http.Client{},
+		Cfg: &config.SlackConfig{
+			WebhookURL:  cfg.WebhookURL,
+			BaseURL:     baseURL,
+			Token:       cfg.Token,
+			Channel:     cfg.Channel,
+			ThreadMatch: cfg.ThreadMatch,
+			Timeout:     cfg.Timeout,
+		},
+		Name: name,
+	}, nil
+}
+
+// Publish implements Sink interface
+func (s *SlackSink) Publish(ctx context.Context, report *models.Report) error {
+	msg, err := s.message(report)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := helpers.WithTimeout(ctx, s.Cfg.Timeout)
+	defer cancel()
+
+	if len(s.Cfg.WebhookURL) > 0 {
+		if err := postJSON(ctx, s.Client, s.Cfg.WebhookURL, nil, msg, nil); err != nil {
+			return fmt.Errorf("post Slack webhook: %v", err)
+		}
+		return nil
+	}
+
+	msg.Channel = s.Cfg.Channel
+	if len(s.Cfg.ThreadMatch) > 0 {
+		msg.ThreadTS, err = s.findThread(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	var resp slackResponse
+	if err := postJSON(ctx, s.Client, s.Cfg.BaseURL+"/chat.postMessage", s.authHeader(), msg, &resp); err != nil {
+		return fmt.Errorf("post Slack message: %v", err)
+	}
+	if !resp.OK {
+		return fmt.Errorf("post Slack message: %s", resp.Error)
+	}
+	return nil
+}
+
+// Preview implements Sink interface
+func (s *SlackSink) Preview(w io.Writer, report *models.Report) error {
+	msg, err := s.message(report)
+	if err != nil {
+		return err
+	}
+	if len(s.Cfg.WebhookURL) > 0 {
+		fmt.Fprintln(w, "POST incoming webhook")
+	} else {
+		msg.Channel = s.Cfg.Channel
+		fmt.Fprintf(w, "POST %s/chat.postMessage\n", s.Cfg.BaseURL)
+	}
+	return previewJSON(w, msg)
+}
+
+// findThread returns ts of today's message in the channel which contains ThreadMatch text.
+func (s *SlackSink) findThread(ctx context.Context) (string, error) {
+	year, month, day := time.Now().Date()
+	startOfDay := time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+
+	query := url.Values{}
+	query.Set("channel", s.Cfg.Channel)
+	query.Set("oldest", strconv.FormatInt(startOfDay.Unix(), 10))
+	query.Set("limit", "200")
+
+	var resp slackResponse
+	if err := send(ctx, s.Client, http.MethodGet, s.Cfg.BaseURL+"/conversations.history?"+query.Encode(), s.authHeader(), nil, &resp); err != nil {
+		return "", fmt.Errorf("get Slack channel history: %v", err)
+	}
+	if !resp.OK {
+		return "", fmt.Errorf("get Slack channel history: %s", resp.Error)
+	}
+
+	for _, m := range resp.Messages {
+		if strings.Contains(m.Text, s.Cfg.ThreadMatch) {
+			return m.TS, nil
+		}
+	}
+	return "", fmt.Errorf("today's Slack message %q not found in channel %s", s.Cfg.ThreadMatch, s.Cfg.Channel)
+}
+
+func (s *SlackSink) authHeader() http.Header {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+s.Cfg.Token)
+	return header
+}
+
+// message returns a message with one section per YESTERDAY and TODAY.
+// Sections longer than Slack limit are split by lines.
+func (s *SlackSink) message(report *models.Report) (*slackMessage, error) {
+	msg := &slackMessage{}
+
+	var text bytes.Buffer
+	for _, part := range []*models.Report{{Done: report.Done}, {Today: report.Today}} {
+		var buf bytes.Buffer
+		if err := render.Slack(&buf, part); err != nil {
+			return nil, err
+		}
+		section := strings.TrimSpace(buf.String())
+		if len(section) < 1 {
+			continue
+		}
+		text.WriteString(section + "\n\n")
+
+		for _, chunk := range splitMessage(section, slackMaxSectionLen) {
+			msg.Blocks = append(msg.Blocks, slackBlock{
+				Type: "section",
+				Text: &slackText{Type: "mrkdwn", Text: chunk},
+			})
+		}
+	}
+	if len(msg.Blocks) > slackMaxBlocks {
+		return nil, fmt.Errorf("report is too long for Slack message: %d blocks", len(msg.Blocks))
+	}
+
+	// Text is shown in notifications and by clients without Block Kit support
+	msg.Text = strings.TrimSpace(text.String())
+	if len(msg.Text) < 1 {
+		msg.Text = "Nothing to report"
+	}
+
+	return msg, nil
+}
@@ -0,0 +1,250 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nemca/taskgram/pkg/config"
+)
+
+// slackRequest is a request received by the fake Slack API
+type slackRequest struct {
+	Method        string
+	Path          string
+	Query         string
+	Authorization string
+	Message       slackMessage
+}
+
+func fakeSlack(t *testing.T, history string) (*httptest.Server, *[]slackRequest) {
+	t.Helper()
+	var requests []slackRequest
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := slackRequest{Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery, Authorization: r.Header.Get("Authorization")}
+		if r.Method == http.MethodPost {
+			if err := json.NewDecoder(r.Body).Decode(&req.Message); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		requests = append(requests, req)
+
+		switch r.URL.Path {
+		case "/hooks/T000/B000":
+			fmt.Fprint(w, "ok")
+		case "/api/chat.postMessage":
+			if req.Message.Channel == "C404" {
+				fmt.Fprint(w, `{"ok":false,"error":"channel_not_found"}`)
+				return
+			}
+			fmt.Fprint(w, `{"ok":true,"ts":"1767690000.000200"}`)
+		case "/api/conversations.history":
+			fmt.Fprint(w, history)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &requests
+}
+
+func TestSlackPublishWebhook(t *testing.T) {
+	srv, requests := fakeSlack(t, "")
+
+	// The webhook is preferred over the token
+	s, err := NewSlackSink("slack", &config.SlackConfig{WebhookURL: srv.URL + "/hooks/T000/B000", Token: "xoxb-secret", Channel: "C123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Publish(context.Background(), testReport()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(*requests))
+	}
+	req := (*requests)[0]
+	if req.Method != http.MethodPost || req.Path != "/hooks/T000/B000" {
+		t.Errorf("request = %s %s, want POST to the webhook", req.Method, req.Path)
+	}
+	if len(req.Authorization) > 0 || len(req.Message.Channel) > 0 {
+		t.Errorf("webhook request has Authorization %q and channel %q", req.Authorization, req.Message.Channel)
+	}
+	if len(req.Message.Blocks) != 2 {
+		t.Fatalf("got %d blocks, want YESTERDAY and TODAY sections", len(req.Message.Blocks))
+	}
+	for _, b := range req.Message.Blocks {
+		if b.Type != "section" || b.Text == nil || b.Text.Type != "mrkdwn" {
+			t.Errorf("block = %+v, want mrkdwn section", b)
+		}
+	}
+	if !strings.Contains(req.Message.Blocks[0].Text.Text, "Fixed rounding") || !strings.Contains(req.Message.Blocks[1].Text.Text, "ACME-2 Invoices") {
+		t.Errorf("blocks miss the report: %+v", req.Message.Blocks)
+	}
+	if !strings.Contains(req.Message.Text, "Fixed rounding") {
+		t.Errorf("fallback text misses the report: %q", req.Message.Text)
+	}
+}
+
+func TestSlackPublishPostMessage(t *testing.T) {
+	year, month, day := time.Now().Date()
+	oldest := strconv.FormatInt(time.Date(year, month, day, 0, 0, 0, 0, time.Local).Unix(), 10)
+	history := `{"ok":true,"messages":[
+		{"ts":"1767690000.000300","text":"Lunch?"},
+		{"ts":"1767690000.000100","text":"Daily standup :thread:"}]}`
+
+	tests := []struct {
+		name         string
+		channel      string
+		threadMatch  string
+		wantPaths    []string
+		wantThreadTS string
+		wantErr      string
+	}{
+		{
+			name:      "channel",
+			channel:   "C123",
+			wantPaths: []string{"/api/chat.postMessage"},
+		},
+		{
+			name:         "thread",
+			channel:      "C123",
+			threadMatch:  "Daily standup",
+			wantPaths:    []string{"/api/conversations.history", "/api/chat.postMessage"},
+			wantThreadTS: "1767690000.000100",
+		},
+		{
+			name:        "thread not found",
+			channel:     "C123",
+			threadMatch: "Retro",
+			wantPaths:   []string{"/api/conversations.history"},
+			wantErr:     `today's Slack message "Retro" not found in channel C123`,
+		},
+		{
+			name:      "not ok",
+			channel:   "C404",
+			wantPaths: []string{"/api/chat.postMessage"},
+			wantErr:   "post Slack message: channel_not_found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := fakeSlack(t, history)
+			s, err := NewSlackSink("slack", &config.SlackConfig{
+				BaseURL:     srv.URL + "/api/",
+				Token:       "xoxb-secret",
+				Channel:     tt.channel,
+				ThreadMatch: tt.threadMatch,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = s.Publish(context.Background(), testReport())
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Publish() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			var paths []string
+			for _, r := range *requests {
+				paths = append(paths, r.Path)
+				if r.Authorization != "Bearer xoxb-secret" {
+					t.Errorf("%s Authorization = %q, want bearer token", r.Path, r.Authorization)
+				}
+				switch r.Path {
+				case "/api/conversations.history":
+					query, err := url.ParseQuery(r.Query)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if query.Get("channel") != tt.channel || query.Get("oldest") != oldest || query.Get("limit") != "200" {
+						t.Errorf("history query = %q, want channel %s since %s", r.Query, tt.channel, oldest)
+					}
+				case "/api/chat.postMessage":
+					if r.Message.Channel != tt.channel || r.Message.ThreadTS != tt.wantThreadTS {
+						t.Errorf("message channel = %q, thread_ts = %q, want %q and %q", r.Message.Channel, r.Message.ThreadTS, tt.channel, tt.wantThreadTS)
+					}
+				}
+			}
+			if strings.Join(paths, " ") != strings.Join(tt.wantPaths, " ") {
+				t.Errorf("requests = %q, want %q", paths, tt.wantPaths)
+			}
+		})
+	}
+}
+
+func TestSlackMessageSplitsSections(t *testing.T) {
+	s, err := NewSlackSink("slack", &config.SlackConfig{WebhookURL: "https://hooks.slack.com/services/T000/B000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := testReport()
+	for i := 0; i < 100; i++ {
+		report.Done.Tasks[0].Notes = append(report.Done.Tasks[0].Notes, fmt.Sprintf("note %d %s", i, strings.Repeat("x", 100)))
+	}
+	msg, err := s.message(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// About 10000 characters of YESTERDAY and one TODAY section
+	if len(msg.Blocks) < 5 {
+		t.Errorf("got %d blocks, want YESTERDAY split to several sections", len(msg.Blocks))
+	}
+	for i, b := range msg.Blocks {
+		if n := utf8.RuneCountInString(b.Text.Text); n > slackMaxSectionLen {
+			t.Errorf("block %d has %d characters, more than %d", i, n, slackMaxSectionLen)
+		}
+	}
+	if last := msg.Blocks[len(msg.Blocks)-1].Text.Text; !strings.Contains(last, "ACME-2 Invoices") {
+		t.Errorf("the last block is not TODAY: %q", last)
+	}
+
+	// Reports which don't fit in 50 blocks are refused
+	for i := 0; i < 2000; i++ {
+		report.Done.Tasks[0].Notes = append(report.Done.Tasks[0].Notes, strings.Repeat("y", 100))
+	}
+	if _, err := s.message(report); err == nil || !strings.Contains(err.Error(), "too long") {
+		t.Errorf("message() error = %v, want too long", err)
+	}
+}
+
+func TestNewSlackSink(t *testing.T) {
+	for _, cfg := range []config.SlackConfig{{}, {Token: "xoxb-secret"}, {Channel: "C123"}} {
+		if _, err := NewSlackSink("slack", &cfg); err == nil {
+			t.Errorf("NewSlackSink(%+v) succeeded, want error", cfg)
+		}
+	}
+}
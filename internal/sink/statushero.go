@@ -0,0 +1,121 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/nemca/taskgram/internal/helpers"
+	"github.com/nemca/taskgram/internal/render"
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+const statusHeroDefaultBaseURL = "https://api.statushero.com"
+
+func init() {
+	Register("statushero", func(cfg *config.SinksConfig) (Sink, error) {
+		return NewStatusHeroSink(cfg.Name, &cfg.StatusHero)
+	})
+}
+
+// StatusHeroSink submits the report as a Status Hero check-in
+type StatusHeroSink struct {
+	Client *http.Client
+	Cfg    *config.StatusHeroConfig
+	Name   string
+}
+
+type statusHeroCheckIn struct {
+	MemberEmail string `json:"member_email,omitempty"`
+	Date        string `json:"date"`
+	Yesterday   string `json:"yesterday"`
+	Today       string `json:"today"`
+}
+
+func NewStatusHeroSink(name string, cfg *config.StatusHeroConfig) (*StatusHeroSink, error) {
+	if len(cfg.APIKey) < 1 || len(cfg.TeamID) < 1 {
+		return nil, fmt.Errorf("statushero_config.apiKey and statushero_config.teamID are required")
+	}
+	baseURL := strings.TrimSuffix(cfg.BaseURL, "/")
+	if len(baseURL) < 1 {
+		baseURL = statusHeroDefaultBaseURL
+	}
+
+	return &StatusHeroSink{
+		Client: &http.Client{},
+		Cfg: &config.StatusHeroConfig{
+			BaseURL:     baseURL,
+			APIKey:      cfg.APIKey,
+			TeamID:      cfg.TeamID,
+			MemberEmail: cfg.MemberEmail,
+			Timeout:     cfg.Timeout,
+		},
+		Name: name,
+	}, nil
+}
+
+// Publish implements Sink interface
+func (s *StatusHeroSink) Publish(ctx context.Context, report *models.Report) error {
+	checkIn, err := s.checkIn(report)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := helpers.WithTimeout(ctx, s.Cfg.Timeout)
+	defer cancel()
+
+	header := http.Header{}
+	header.Set("X-Team-ID", s.Cfg.TeamID)
+	header.Set("X-API-Key", s.Cfg.APIKey)
+	if err := postJSON(ctx, s.Client, s.Cfg.BaseURL+"/api/v1/statuses", header, checkIn, nil); err != nil {
+		return fmt.Errorf("submit Status Hero check-in: %v", err)
+	}
+	return nil
+}
+
+// Preview implements Sink interface
+func (s *StatusHeroSink) Preview(w io.Writer, report *models.Report) error {
+	checkIn, err := s.checkIn(report)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "POST %s/api/v1/statuses\n", s.Cfg.BaseURL)
+	return previewJSON(w, checkIn)
+}
+
+func (s *StatusHeroSink) checkIn(report *models.Report) (*statusHeroCheckIn, error) {
+	var yesterday, today bytes.Buffer
+	if err := render.MarkdownItems(&yesterday, &report.Done); err != nil {
+		return nil, err
+	}
+	if err := render.MarkdownItems(&today, &report.Today); err != nil {
+		return nil, err
+	}
+
+	return &statusHeroCheckIn{
+		MemberEmail: s.Cfg.MemberEmail,
+		Date:        report.End.Format("2006-01-02"),
+		Yesterday:   strings.TrimSpace(yesterday.String()),
+		Today:       strings.TrimSpace(today.String()),
+	}, nil
+}
@@ -0,0 +1,121 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nemca/taskgram/internal/render"
+	"github.com/nemca/taskgram/pkg/config"
+)
+
+func TestStatusHeroPublish(t *testing.T) {
+	var path, teamID, apiKey, contentType string
+	var checkIn statusHeroCheckIn
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		path = r.URL.Path
+		teamID = r.Header.Get("X-Team-ID")
+		apiKey = r.Header.Get("X-API-Key")
+		contentType = r.Header.Get("Content-Type")
+		if err := json.NewDecoder(r.Body).Decode(&checkIn); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	s, err := NewStatusHeroSink("statushero", &config.StatusHeroConfig{
+		BaseURL:     srv.URL + "/",
+		APIKey:      "secret",
+		TeamID:      "team-1",
+		MemberEmail: "john@example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := testReport()
+	if err := s.Publish(context.Background(), report); err != nil {
+		t.Fatal(err)
+	}
+
+	if path != "/api/v1/statuses" {
+		t.Errorf("path = %q, want /api/v1/statuses", path)
+	}
+	if teamID != "team-1" || apiKey != "secret" {
+		t.Errorf("X-Team-ID = %q, X-API-Key = %q, want team-1 and secret", teamID, apiKey)
+	}
+	if contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", contentType)
+	}
+
+	var yesterday, today bytes.Buffer
+	if err := render.MarkdownItems(&yesterday, &report.Done); err != nil {
+		t.Fatal(err)
+	}
+	if err := render.MarkdownItems(&today, &report.Today); err != nil {
+		t.Fatal(err)
+	}
+	want := statusHeroCheckIn{
+		MemberEmail: "john@example.com",
+		Date:        "2026-01-06",
+		Yesterday:   strings.TrimSpace(yesterday.String()),
+		Today:       strings.TrimSpace(today.String()),
+	}
+	if !reflect.DeepEqual(checkIn, want) {
+		t.Errorf("check-in = %+v, want %+v", checkIn, want)
+	}
+	if !strings.Contains(checkIn.Yesterday, "Fixed rounding") || !strings.Contains(checkIn.Today, "ACME-2 Invoices") {
+		t.Errorf("check-in misses report tasks: %+v", checkIn)
+	}
+}
+
+func TestStatusHeroPublishError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"invalid API key"}`, http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	s, err := NewStatusHeroSink("statushero", &config.StatusHeroConfig{BaseURL: srv.URL, APIKey: "wrong", TeamID: "team-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Publish(context.Background(), testReport())
+	if err == nil || !strings.Contains(err.Error(), "401 Unauthorized") || !strings.Contains(err.Error(), "invalid API key") {
+		t.Errorf("Publish() error = %v, want 401 error with the response body", err)
+	}
+}
+
+func TestNewStatusHeroSinkRequiresCredentials(t *testing.T) {
+	for _, cfg := range []config.StatusHeroConfig{{APIKey: "secret"}, {TeamID: "team-1"}} {
+		if _, err := NewStatusHeroSink("statushero", &cfg); err == nil {
+			t.Errorf("NewStatusHeroSink(%+v) succeeded, want error", cfg)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nemca/taskgram/internal/helpers"
+	"github.com/nemca/taskgram/internal/render"
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+const (
+	telegramDefaultBaseURL = "https://api.telegram.org"
+	// telegramMaxMessageLen is the maximum length of a message text
+	telegramMaxMessageLen = 4096
+)
+
+func init() {
+	Register("telegram", func(cfg *config.SinksConfig) (Sink, error) {
+		return NewTelegramSink(cfg.Name, &cfg.Telegram)
+	})
+}
+
+// TelegramSink sends the report to a chat via Telegram Bot API
+type TelegramSink struct {
+	Client *http.Client
+	Cfg    *config.TelegramConfig
+	Name   string
+}
+
+type telegramMessage struct {
+	ChatID                string `json:"chat_id"`
+	Text                  string `json:"text"`
+	ParseMode             string `json:"parse_mode"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview"`
+}
+
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+}
+
+func NewTelegramSink(name string, cfg *config.TelegramConfig) (*TelegramSink, error) {
+	if len(cfg.Token) < 1 || len(cfg.ChatID) < 1 {
+		return nil, fmt.Errorf("telegram_config.token and telegram_config.chatID are required")
+	}
+	baseURL := strings.TrimSuffix(cfg.BaseURL, "/")
+	if len(baseURL) < 1 {
+		baseURL = telegramDefaultBaseURL
+	}
+
+	return &TelegramSink{
+		Client: &http.Client{},
+		Cfg: &config.TelegramConfig{
+			BaseURL: baseURL,
+			Token:   cfg.Token,
+			ChatID:  cfg.ChatID,
+			Timeout: cfg.Timeout,
+		},
+		Name: name,
+	}, nil
+}
+
+// Publish implements Sink interface
+func (s *TelegramSink) Publish(ctx context.Context, report *models.Report) error {
+	messages, err := s.messages(report)
+	if err != nil {
+		return err
+	}
+
+	for i, msg := range messages {
+		if err := s.send(ctx, msg); err != nil {
+			return fmt.Errorf("send Telegram message %d of %d: %v", i+1, len(messages), err)
+		}
+	}
+	return nil
+}
+
+// Preview implements Sink interface
+func (s *TelegramSink) Preview(w io.Writer, report *models.Report) error {
+	messages, err := s.messages(report)
+	if err != nil {
+		return err
+	}
+	for i, msg := range messages {
+		fmt.Fprintf(w, "Message %d of %d to chat %s:\n%s\n", i+1, len(messages), msg.ChatID, msg.Text)
+	}
+	return nil
+}
+
+func (s *TelegramSink) send(ctx context.Context, msg telegramMessage) error {
+	ctx, cancel := helpers.WithTimeout(ctx, s.Cfg.Timeout)
+	defer cancel()
+
+	var resp telegramResponse
+	url := fmt.Sprintf("%s/bot%s/sendMessage", s.Cfg.BaseURL, s.Cfg.Token)
+	if err := postJSON(ctx, s.Client, url, nil, msg, &resp); err != nil {
+		// Don't leak the bot token in errors
+		return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), s.Cfg.Token, "<token>"))
+	}
+	if !resp.OK {
+		return fmt.Errorf("telegram: %s", resp.Description)
+	}
+	return nil
+}
+
+func (s *TelegramSink) messages(report *models.Report) ([]telegramMessage, error) {
+	var buf bytes.Buffer
+	if err := render.Telegram(&buf, report); err != nil {
+		return nil, err
+	}
+
+	var messages []telegramMessage
+	for _, text := range splitMessage(strings.TrimSpace(buf.String()), telegramMaxMessageLen) {
+		messages = append(messages, telegramMessage{
+			ChatID:                s.Cfg.ChatID,
+			Text:                  text,
+			ParseMode:             "MarkdownV2",
+			DisableWebPagePreview: true,
+		})
+	}
+	return messages, nil
+}
+
+// splitMessage splits text to parts of at most limit characters by lines.
+// Lines longer than limit are split by characters not breaking escape sequences.
+func splitMessage(text string, limit int) []string {
+	var parts []string
+	var current strings.Builder
+	currentLen := 0
+
+	flush := func() {
+		if currentLen > 0 {
+			parts = append(parts, strings.TrimRight(current.String(), "\n"))
+			current.Reset()
+			currentLen = 0
+		}
+	}
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		lineLen := utf8.RuneCountInString(line)
+		if currentLen+lineLen > limit {
+			flush()
+		}
+		for lineLen > limit {
+			head, tail := splitRunes(line, limit)
+			parts = append(parts, head)
+			line = tail
+			lineLen = utf8.RuneCountInString(line)
+		}
+		if currentLen < 1 && line == "\n" {
+			// Don't start a message with an empty line
+			continue
+		}
+		current.WriteString(line)
+		currentLen += lineLen
+	}
+	flush()
+
+	return parts
+}
+
+// splitRunes splits s after at most n runes, moving a trailing
+// escape backslash to the tail.
+func splitRunes(s string, n int) (head, tail string) {
+	runes := []rune(s)
+	i := n
+	// Count trailing backslashes, odd number means a broken escape sequence
+	backslashes := 0
+	for j := i - 1; j >= 0 && runes[j] == '\\'; j-- {
+		backslashes++
+	}
+	if backslashes%2 == 1 {
+		i--
+	}
+	return string(runes[:i]), string(runes[i:])
+}
@@ -0,0 +1,192 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/nemca/taskgram/pkg/config"
+)
+
+func TestSplitMessage(t *testing.T) {
+	a := func(n int) string { return strings.Repeat("a", n) }
+
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "short",
+			text: "line 1\nline 2",
+			want: []string{"line 1\nline 2"},
+		},
+		{
+			name: "exactly limit",
+			text: a(4096),
+			want: []string{a(4096)},
+		},
+		{
+			name: "limit plus one",
+			text: a(4097),
+			want: []string{a(4096), "a"},
+		},
+		{
+			name: "runes not bytes",
+			text: strings.Repeat("я", 4096),
+			want: []string{strings.Repeat("я", 4096)},
+		},
+		{
+			name: "split by lines",
+			text: a(4095) + "\n" + "b",
+			want: []string{a(4095), "b"},
+		},
+		{
+			name: "lines fill the limit",
+			text: a(2047) + "\n" + a(2047) + "\nb",
+			want: []string{a(2047) + "\n" + a(2047), "b"},
+		},
+		{
+			name: "long line with newline",
+			text: a(4096) + "\nb",
+			want: []string{a(4096), "b"},
+		},
+		{
+			name: "empty line after split",
+			text: a(4095) + "\n\nb",
+			want: []string{a(4095), "b"},
+		},
+		{
+			name: "escape sequence is not broken",
+			text: a(4095) + `\.`,
+			want: []string{a(4095), `\.`},
+		},
+		{
+			name: "escaped backslash is split",
+			text: a(4094) + `\\.`,
+			want: []string{a(4094) + `\\`, "."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitMessage(tt.text, telegramMaxMessageLen)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitMessage() returned %d parts, want %d", len(got), len(tt.want))
+				for i, part := range got {
+					t.Logf("part %d: %d runes", i, utf8.RuneCountInString(part))
+				}
+			}
+		})
+	}
+}
+
+func TestTelegramPublish(t *testing.T) {
+	var paths []string
+	var messages []telegramMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		var msg telegramMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		messages = append(messages, msg)
+		fmt.Fprint(w, `{"ok":true,"result":{}}`)
+	}))
+	defer srv.Close()
+
+	s, err := NewTelegramSink("telegram", &config.TelegramConfig{BaseURL: srv.URL, Token: "123:secret", ChatID: "-100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := testReport()
+	// Long notes make the report longer than one message
+	report.Done.Tasks[0].Notes = append(report.Done.Tasks[0].Notes, strings.Repeat("x", 3000), strings.Repeat("y", 3000))
+	if err := s.Publish(context.Background(), report); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) < 2 {
+		t.Fatalf("sent %d messages, want the report split", len(messages))
+	}
+	for i, msg := range messages {
+		if paths[i] != "/bot123:secret/sendMessage" {
+			t.Errorf("message %d path = %q, want /bot123:secret/sendMessage", i, paths[i])
+		}
+		if msg.ChatID != "-100" || msg.ParseMode != "MarkdownV2" || !msg.DisableWebPagePreview {
+			t.Errorf("message %d = %+v, want MarkdownV2 message to chat -100 without preview", i, msg)
+		}
+		if n := utf8.RuneCountInString(msg.Text); n > telegramMaxMessageLen || n < 1 {
+			t.Errorf("message %d has %d characters", i, n)
+		}
+	}
+	if !strings.Contains(messages[0].Text, "Fixed rounding") {
+		t.Errorf("first message misses the report:\n%s", messages[0].Text)
+	}
+}
+
+func TestTelegramPublishError(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{
+			name: "not ok",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"ok":false,"description":"Bad Request: chat not found"}`)
+			},
+			want: "telegram: Bad Request: chat not found",
+		},
+		{
+			name: "status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, `{"ok":false,"description":"Unauthorized"}`, http.StatusUnauthorized)
+			},
+			want: "POST /bot<token>/sendMessage: unexpected status 401 Unauthorized",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			s, err := NewTelegramSink("telegram", &config.TelegramConfig{BaseURL: srv.URL, Token: "123:secret", ChatID: "-100"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = s.Publish(context.Background(), testReport())
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Publish() error = %v, want %q", err, tt.want)
+			}
+			if err != nil && strings.Contains(err.Error(), "secret") {
+				t.Errorf("Publish() error leaks the token: %v", err)
+			}
+		})
+	}
+}
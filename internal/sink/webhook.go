@@ -0,0 +1,106 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/nemca/taskgram/internal/helpers"
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+const webhookDefaultSignatureHeader = "X-Taskgram-Signature"
+
+func init() {
+	Register("webhook", func(cfg *config.SinksConfig) (Sink, error) {
+		return NewWebhookSink(cfg.Name, &cfg.Webhook)
+	})
+}
+
+// WebhookSink posts the report as JSON to the URL
+type WebhookSink struct {
+	Client *http.Client
+	Cfg    *config.WebhookConfig
+	Name   string
+}
+
+func NewWebhookSink(name string, cfg *config.WebhookConfig) (*WebhookSink, error) {
+	if len(cfg.URL) < 1 {
+		return nil, fmt.Errorf("webhook_config.url is required")
+	}
+
+	sinkCfg := *cfg
+	if len(sinkCfg.SignatureHeader) < 1 {
+		sinkCfg.SignatureHeader = webhookDefaultSignatureHeader
+	}
+
+	return &WebhookSink{
+		Client: &http.Client{},
+		Cfg:    &sinkCfg,
+		Name:   name,
+	}, nil
+}
+
+// Publish implements Sink interface
+func (s *WebhookSink) Publish(ctx context.Context, report *models.Report) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := helpers.WithTimeout(ctx, s.Cfg.Timeout)
+	defer cancel()
+
+	if err := send(ctx, s.Client, http.MethodPost, s.Cfg.URL, s.header(body), body, nil); err != nil {
+		return fmt.Errorf("post webhook: %v", err)
+	}
+	return nil
+}
+
+// Preview implements Sink interface
+func (s *WebhookSink) Preview(w io.Writer, report *models.Report) error {
+	fmt.Fprintf(w, "POST %s\n", s.Cfg.URL)
+	return previewJSON(w, report)
+}
+
+// header returns custom headers and the signature of the body.
+func (s *WebhookSink) header(body []byte) http.Header {
+	header := http.Header{}
+	for k, v := range s.Cfg.Headers {
+		header.Set(k, v)
+	}
+	if len(s.Cfg.Secret) > 0 {
+		header.Set(s.Cfg.SignatureHeader, "sha256="+Signature(s.Cfg.Secret, body))
+	}
+	return header
+}
+
+// Signature returns hex encoded HMAC-SHA256 of the body.
+// Receivers should compute it over the raw request body and compare with hmac.Equal.
+func Signature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
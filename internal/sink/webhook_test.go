@@ -0,0 +1,133 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nemca/taskgram/pkg/config"
+)
+
+func TestSignature(t *testing.T) {
+	// Well-known HMAC-SHA256 test vector
+	got := Signature("key", []byte("The quick brown fox jumps over the lazy dog"))
+	want := "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"
+	if got != want {
+		t.Errorf("Signature() = %s, want %s", got, want)
+	}
+}
+
+func TestWebhookPublish(t *testing.T) {
+	tests := []struct {
+		name            string
+		secret          string
+		signatureHeader string
+		wantHeader      string
+	}{
+		{name: "default header", secret: "s3cret", wantHeader: "X-Taskgram-Signature"},
+		{name: "custom header", secret: "s3cret", signatureHeader: "X-Hub-Signature-256", wantHeader: "X-Hub-Signature-256"},
+		{name: "unsigned"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header http.Header
+			var body []byte
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/hooks/standup" {
+					http.NotFound(w, r)
+					return
+				}
+				header = r.Header
+				body, _ = ioutil.ReadAll(r.Body)
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			s, err := NewWebhookSink("webhook", &config.WebhookConfig{
+				URL:             srv.URL + "/hooks/standup",
+				Headers:         map[string]string{"Authorization": "Bearer t0ken", "X-Team": "billing"},
+				Secret:          tt.secret,
+				SignatureHeader: tt.signatureHeader,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			report := testReport()
+			if err := s.Publish(context.Background(), report); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := header.Get("Authorization"); got != "Bearer t0ken" {
+				t.Errorf("Authorization = %q, want the configured header", got)
+			}
+			if got := header.Get("X-Team"); got != "billing" {
+				t.Errorf("X-Team = %q, want the configured header", got)
+			}
+			if got := header.Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+
+			want, err := json.Marshal(report)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != string(want) {
+				t.Errorf("body = %s, want the report JSON %s", body, want)
+			}
+
+			if len(tt.secret) < 1 {
+				if got := header.Get(webhookDefaultSignatureHeader); len(got) > 0 {
+					t.Errorf("unsigned request has signature %q", got)
+				}
+				return
+			}
+			// The signature is computed over the exact request body
+			mac := hmac.New(sha256.New, []byte(tt.secret))
+			mac.Write(body)
+			wantSignature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+			if got := header.Get(tt.wantHeader); got != wantSignature {
+				t.Errorf("%s = %q, want %q", tt.wantHeader, got, wantSignature)
+			}
+		})
+	}
+}
+
+func TestWebhookPublishError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	s, err := NewWebhookSink("webhook", &config.WebhookConfig{URL: srv.URL + "/hooks", Secret: "wrong"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Publish(context.Background(), testReport())
+	if err == nil || err.Error() != "post webhook: POST /hooks: unexpected status 403 Forbidden: invalid signature" {
+		t.Errorf("Publish() error = %v", err)
+	}
+}
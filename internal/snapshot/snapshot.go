@@ -0,0 +1,135 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package snapshot stores reports of successful runs to render them offline.
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+// Keep is the number of snapshots kept in the store
+const Keep = 30
+
+// fileLayout is the layout of snapshot file names, sortable by time
+const fileLayout = "20060102T150405.000000000Z"
+
+var ErrNotFound = errors.New("no snapshot covering the search window")
+
+// Snapshot is a report collected at some time
+type Snapshot struct {
+	CreatedAt time.Time     `json:"created_at"`
+	Report    models.Report `json:"report"`
+}
+
+// Store keeps snapshots as JSON files in the directory
+type Store struct {
+	Dir string
+}
+
+// New returns store in the directory.
+func New(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// Save writes the report as a new snapshot and removes old snapshots above Keep.
+func (s *Store) Save(report *models.Report) error {
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return err
+	}
+
+	snap := Snapshot{CreatedAt: time.Now(), Report: *report}
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(s.Dir, snap.CreatedAt.UTC().Format(fileLayout)+".json")
+	if err := ioutil.WriteFile(name, b, 0600); err != nil {
+		return err
+	}
+
+	names, err := s.names()
+	if err != nil {
+		return err
+	}
+	for len(names) > Keep {
+		if err := os.Remove(filepath.Join(s.Dir, names[0])); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// Latest returns the most recent snapshot covering start of the search window.
+// Items after the end of the snapshot window are missing,
+// events ended before the search window are removed.
+// Returns ErrNotFound if there is no such snapshot.
+func (s *Store) Latest(start time.Time) (*Snapshot, error) {
+	names, err := s.names()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(names) - 1; i >= 0; i-- {
+		b, err := ioutil.ReadFile(filepath.Join(s.Dir, names[i]))
+		if err != nil {
+			return nil, err
+		}
+		var snap Snapshot
+		if err := json.Unmarshal(b, &snap); err != nil {
+			return nil, err
+		}
+		if snap.Report.Start.After(start) || !snap.Report.End.After(start) {
+			continue
+		}
+
+		snap.Report.Done.Events = snap.Report.Done.Events.EndedAfter(start)
+		snap.Report.Today.Events = snap.Report.Today.Events.EndedAfter(start)
+		return &snap, nil
+	}
+
+	return nil, ErrNotFound
+}
+
+// names returns sorted snapshot file names, oldest first
+func (s *Store) names() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
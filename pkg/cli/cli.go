@@ -0,0 +1,270 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cli implements the taskgram command.
+// A custom build with in-house sources registered in the source package
+// runs Main from its own main package.
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/nemca/taskgram/internal/cache"
+	"github.com/nemca/taskgram/internal/helpers"
+	"github.com/nemca/taskgram/internal/render"
+	"github.com/nemca/taskgram/internal/repository"
+	"github.com/nemca/taskgram/internal/sink"
+	"github.com/nemca/taskgram/internal/snapshot"
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+	"github.com/nemca/taskgram/pkg/source"
+)
+
+// Main runs taskgram with command line flags and the config file.
+func Main() {
+	cfg, err := config.Init()
+	if err != nil {
+		log.Fatalf("reading config: %v", err)
+	}
+	if cfg == nil {
+		log.Fatalf("config is empty")
+	}
+
+	// Subcommands
+	if len(cfg.Args) > 0 {
+		if err := runCommand(cfg, cfg.Args); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return
+	}
+
+	// Check that either only dates or only times
+	if len(cfg.Search.LastEditedTimeEnd) > 0 &&
+		(len(cfg.Search.LastEditedDateStart) > 0 || len(cfg.Search.LastEditedDateEnd) > 0) {
+		fmt.Fprintf(os.Stderr, "Please, use either only dates or only times for search.\n")
+		os.Exit(1)
+	}
+
+	// Prepare times for search requests
+	var searchTimeStart, searchTimeEnd time.Time
+
+	tomorrow, _ := helpers.ParseDuration("tomorrow")
+	searchTimeEnd = time.Now().Add(-tomorrow)
+	// Parse times from config to time.Time
+	if len(cfg.Search.LastEditedTimeStart) > 0 {
+		lastEditedTimeStart, err := helpers.ParseDuration(cfg.Search.LastEditedTimeStart)
+		if err != nil {
+			log.Fatalf("convert start last edited time from config: %v", err)
+		}
+		searchTimeStart = time.Now().Add(-lastEditedTimeStart)
+		// If end last edited time not set use now
+		searchTimeEnd = time.Now()
+		if len(cfg.Search.LastEditedTimeEnd) > 0 {
+			lastEditedTimeEnd, err := helpers.ParseDuration(cfg.Search.LastEditedTimeEnd)
+			if err != nil {
+				log.Fatalf("convert end last edited time from config: %v", err)
+			}
+			searchTimeEnd = time.Now().Add(-lastEditedTimeEnd)
+		}
+	}
+	// Parse dates from config to time.Time
+	if len(cfg.Search.LastEditedDateStart) > 0 {
+		lastEditedDateStart, err := helpers.ParseDate(cfg.Search.LastEditedDateStart)
+		if err != nil {
+			log.Fatalf("convert start last edited date from config: %v", err)
+		}
+		searchTimeStart = time.Now().Add(-lastEditedDateStart)
+		// If end last edited date not set use now
+		if len(cfg.Search.LastEditedDateEnd) > 0 {
+			lastEditedDateEnd, err := helpers.ParseDate(cfg.Search.LastEditedDateEnd)
+			if err != nil {
+				log.Fatalf("convert end last edited date from config: %v", err)
+			}
+			searchTimeEnd = time.Now().Add(-lastEditedDateEnd)
+		}
+	}
+
+	renderer, err := newRenderer(cfg)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	// Show the times for search
+	fmt.Fprintf(os.Stderr, "Finding notes from %q to %q:\n\n", searchTimeStart.Format(time.RFC1123), searchTimeEnd.Format(time.RFC1123))
+
+	report := &models.Report{
+		Start: searchTimeStart,
+		End:   searchTimeEnd,
+	}
+	searchConfig := &models.SearchConfig{
+		LastEditedTimeStart: searchTimeStart,
+		LastEditedTimeEnd:   searchTimeEnd,
+	}
+
+	if err := enableCache(cfg); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	ctx, cancel := newContext(cfg)
+	defer cancel()
+
+	dir, err := dataDir(cfg)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	snapshots := snapshot.New(filepath.Join(dir, "snapshots"))
+
+	var failed []source.Result
+	if cfg.Offline {
+		snap, err := snapshots.Latest(searchTimeStart)
+		if err != nil {
+			log.Fatalf("offline: %v", err)
+		}
+		report = &snap.Report
+		report.SnapshotAt = &snap.CreatedAt
+		fmt.Fprintf(os.Stderr, "OFFLINE: using snapshot taken %s ago, notes after %q are missing.\n\n",
+			time.Since(snap.CreatedAt).Round(time.Minute), snap.Report.End.Format(time.RFC1123))
+	} else {
+		failed = fetchReport(ctx, cfg, report, searchConfig)
+
+		// Keep only complete reports for offline mode and history
+		if len(failed) < 1 {
+			if err := snapshots.Save(report); err != nil {
+				fmt.Fprintf(os.Stderr, "WARNING: save snapshot: %v\n", err)
+			}
+			if err := addHistory(dir, report); err != nil {
+				fmt.Fprintf(os.Stderr, "WARNING: save history: %v\n", err)
+			}
+		}
+	}
+
+	// Print search results
+	if err := renderer.Render(os.Stdout, report); err != nil {
+		log.Fatalf("render report: %v", err)
+	}
+
+	// Don't publish the stale report as today's check-in
+	if cfg.Offline && cfg.Publish {
+		fmt.Fprintf(os.Stderr, "The report is rendered offline, not published.\n")
+		os.Exit(1)
+	}
+
+	// Show failed targets and don't publish the partial report
+	if len(failed) > 0 {
+		printFailed(failed)
+		if cfg.Publish {
+			fmt.Fprintf(os.Stderr, "The report is partial, not published.\n")
+		}
+		os.Exit(1)
+	}
+
+	// Publish to sinks
+	if cfg.Publish || cfg.DryRun {
+		for i := range cfg.Sinks {
+			sinkCfg := &cfg.Sinks[i]
+			s, err := sink.New(sinkCfg)
+			if err != nil {
+				log.Fatalf("create sink %s: %v", sinkCfg.Name, err)
+			}
+
+			if cfg.DryRun {
+				fmt.Fprintf(os.Stderr, "Would publish to %q:\n", sinkCfg.Name)
+				if err := s.Preview(os.Stderr, report); err != nil {
+					log.Fatalf("preview sink %s: %v", sinkCfg.Name, err)
+				}
+				fmt.Fprintln(os.Stderr)
+				continue
+			}
+			if err := s.Publish(ctx, report); err != nil {
+				log.Fatalf("publish to %s: %v", sinkCfg.Name, err)
+			}
+			fmt.Fprintf(os.Stderr, "Published to %q\n", sinkCfg.Name)
+		}
+	}
+}
+
+// fetchReport fetches all targets to the report and returns failed ones
+func fetchReport(ctx context.Context, cfg *config.Config, report *models.Report, sc *models.SearchConfig) (failed []source.Result) {
+	for _, result := range source.FetchAll(ctx, cfg.Targets, sc) {
+		if result.Err != nil {
+			failed = append(failed, result)
+			continue
+		}
+		report.Targets = append(report.Targets, result.Target)
+		report.Done.Append(result.Done)
+		report.Today.Append(result.Today)
+	}
+	// Attach notes from commits, issues, etc. to the tasks they refer to
+	report.Done.Tasks = report.Done.Tasks.AttachRefs()
+	report.Today.Tasks = report.Today.Tasks.AttachRefs()
+
+	return failed
+}
+
+func printFailed(failed []source.Result) {
+	fmt.Fprintln(os.Stderr)
+	for _, result := range failed {
+		fmt.Fprintf(os.Stderr, "source %s failed: %v\n", result.Target, result.Err)
+	}
+}
+
+// newContext returns context which is cancelled by Ctrl-C or the global deadline,
+// so all in-flight requests are aborted
+func newContext(cfg *config.Config) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	ctx, cancel := helpers.WithTimeout(ctx, cfg.Timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// enableCache enables the cache of Notion block trees unless it's disabled
+func enableCache(cfg *config.Config) error {
+	if cfg.Cache.Disabled {
+		return nil
+	}
+	c, err := cache.New(cfg.Cache.Dir)
+	if err != nil {
+		return fmt.Errorf("open cache: %v", err)
+	}
+	repository.SetCache(c)
+	return nil
+}
+
+// dataDir returns the directory for persistent data from config or the default one
+func dataDir(cfg *config.Config) (string, error) {
+	if len(cfg.DataDir) > 0 {
+		return cfg.DataDir, nil
+	}
+	return helpers.DataDir()
+}
+
+// newRenderer returns the report template renderer if it's set for markdown format
+// or the renderer of the format
+func newRenderer(cfg *config.Config) (render.Renderer, error) {
+	if len(cfg.Report.Template) > 0 && (len(cfg.Format) < 1 || cfg.Format == render.DefaultFormat) {
+		return render.NewTemplate(cfg.Report.Template)
+	}
+	return render.New(cfg.Format)
+}
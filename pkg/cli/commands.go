@@ -0,0 +1,280 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nemca/taskgram/internal/cache"
+	"github.com/nemca/taskgram/internal/helpers"
+	"github.com/nemca/taskgram/internal/history"
+	"github.com/nemca/taskgram/internal/render"
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+const (
+	historyFile  = "history.db"
+	historyUsage = "usage: taskgram history list|show <date>|search <query>"
+	reportUsage  = "usage: taskgram report [--period week|month|sprint]"
+
+	defaultSprintLength = "2w"
+)
+
+// periods of the summary report
+var periods = map[string]string{
+	"week":  "1w",
+	"month": "30d",
+}
+
+// runCommand runs a subcommand by positional arguments, e.g. "cache clear".
+func runCommand(cfg *config.Config, args []string) error {
+	switch args[0] {
+	case "cache":
+		return cacheCommand(cfg, args[1:])
+	case "history":
+		return historyCommand(cfg, args[1:])
+	case "report":
+		return reportCommand(cfg, args[1:])
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func cacheCommand(cfg *config.Config, args []string) error {
+	if len(args) != 1 || args[0] != "clear" {
+		return fmt.Errorf("usage: taskgram cache clear")
+	}
+
+	c, err := cache.New(cfg.Cache.Dir)
+	if err != nil {
+		return err
+	}
+	if err := c.Clear(); err != nil {
+		return fmt.Errorf("clear cache: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "Cache %s cleared\n", c.Dir)
+	return nil
+}
+
+func historyCommand(cfg *config.Config, args []string) error {
+	if len(args) < 1 {
+		return errors.New(historyUsage)
+	}
+
+	dir, err := dataDir(cfg)
+	if err != nil {
+		return err
+	}
+	store, err := history.Open(filepath.Join(dir, historyFile))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		return historyList(store)
+	case args[0] == "show" && len(args) == 2:
+		return historyShow(cfg, store, args[1])
+	case args[0] == "search" && len(args) > 1:
+		return historySearch(store, strings.Join(args[1:], " "))
+	}
+	return errors.New(historyUsage)
+}
+
+func historyList(store *history.Store) error {
+	entries, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "CREATED\tWINDOW\tSOURCES\tTASKS\tNOTES\tMEETINGS\n")
+	for _, e := range entries {
+		r := &e.Report
+		fmt.Fprintf(w, "%s\t%s - %s\t%s\t%d\t%d\t%d\n",
+			e.CreatedAt.Local().Format("2006-01-02 15:04"),
+			r.Start.Local().Format("Jan 2 15:04"), r.End.Local().Format("Jan 2 15:04"),
+			strings.Join(r.Targets, ", "),
+			tasksWithNotes(r.Done.Tasks), r.Done.Tasks.NotesLen(), r.Done.Events.EventsLen())
+	}
+	return w.Flush()
+}
+
+func historyShow(cfg *config.Config, store *history.Store, date string) error {
+	day, err := time.ParseInLocation("2006-01-02", date, time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+	}
+	entry, err := store.Day(day)
+	if errors.Is(err, history.ErrNotFound) {
+		return fmt.Errorf("no report for %s", date)
+	}
+	if err != nil {
+		return err
+	}
+
+	renderer, err := newRenderer(cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Report of %s from %q to %q:\n\n", entry.CreatedAt.Local().Format("2006-01-02 15:04"),
+		entry.Report.Start.Format(time.RFC1123), entry.Report.End.Format(time.RFC1123))
+	return renderer.Render(os.Stdout, &entry.Report)
+}
+
+func historySearch(store *history.Store, query string) error {
+	matches, err := store.Search(query)
+	if err != nil {
+		return err
+	}
+	if len(matches) < 1 {
+		fmt.Fprintf(os.Stderr, "Nothing found for %q\n", query)
+		return nil
+	}
+
+	for _, m := range matches {
+		date := m.CreatedAt.Local().Format("2006-01-02")
+		if m.Event != nil {
+			fmt.Printf("%s  Meeting: %s\n", date, m.Event.Summary)
+			continue
+		}
+		fmt.Printf("%s  %s", date, m.Task.Title)
+		for _, p := range m.Task.Projects {
+			fmt.Printf(" #%s", strings.ToLower(p))
+		}
+		fmt.Println()
+		for _, note := range m.Notes {
+			fmt.Printf("              - %s\n", note)
+		}
+	}
+	return nil
+}
+
+// reportCommand prints done tasks and meetings of the period grouped by projects
+func reportCommand(cfg *config.Config, args []string) error {
+	if len(args) > 0 {
+		return errors.New(reportUsage)
+	}
+
+	summarize, err := render.NewSummary(cfg.Format)
+	if err != nil {
+		return err
+	}
+
+	end := time.Now()
+	start, err := periodStart(&cfg.Report, end)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Summarizing notes from %q to %q:\n\n", start.Format(time.RFC1123), end.Format(time.RFC1123))
+
+	if err := enableCache(cfg); err != nil {
+		return err
+	}
+	ctx, cancel := newContext(cfg)
+	defer cancel()
+
+	report := &models.Report{
+		Start: start,
+		End:   end,
+	}
+	searchConfig := &models.SearchConfig{
+		LastEditedTimeStart: start,
+		LastEditedTimeEnd:   end,
+	}
+	failed := fetchReport(ctx, cfg, report, searchConfig)
+
+	if err := summarize(os.Stdout, models.Summarize(report)); err != nil {
+		return fmt.Errorf("render summary: %v", err)
+	}
+
+	if len(failed) > 0 {
+		printFailed(failed)
+		return errors.New("the summary is partial")
+	}
+	return nil
+}
+
+// periodStart returns the start of the summary period ending at end.
+// The sprint starts SprintLength ago or, if SprintStart is set,
+// at the start of the current sprint counting from SprintStart.
+func periodStart(cfg *config.ReportConfig, end time.Time) (time.Time, error) {
+	period := cfg.Period
+	if len(period) < 1 {
+		period = "week"
+	}
+
+	if period != "sprint" {
+		length, ok := periods[period]
+		if !ok {
+			return time.Time{}, fmt.Errorf("unknown period %q, %s", period, reportUsage)
+		}
+		d, _ := helpers.ParseDuration(length)
+		return end.Add(-d), nil
+	}
+
+	length := cfg.SprintLength
+	if len(length) < 1 {
+		length = defaultSprintLength
+	}
+	d, err := helpers.ParseDuration(length)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("convert sprint length from config: %v", err)
+	}
+	if d <= 0 {
+		return time.Time{}, fmt.Errorf("sprint length %q must be positive", length)
+	}
+	if len(cfg.SprintStart) < 1 {
+		return end.Add(-d), nil
+	}
+
+	anchor, err := time.ParseInLocation("2006-01-02", cfg.SprintStart, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid sprint start %q, expected YYYY-MM-DD", cfg.SprintStart)
+	}
+	if anchor.After(end) {
+		return time.Time{}, fmt.Errorf("sprint start %s is in the future", cfg.SprintStart)
+	}
+	return anchor.Add(end.Sub(anchor) / d * d), nil
+}
+
+func tasksWithNotes(tasks models.Tasks) (n int) {
+	for _, t := range tasks {
+		if len(t.Notes) > 0 {
+			n++
+		}
+	}
+	return
+}
+
+// addHistory records the report in the history database in the data directory
+func addHistory(dir string, report *models.Report) error {
+	store, err := history.Open(filepath.Join(dir, historyFile))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	return store.Add(report)
+}
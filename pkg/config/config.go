@@ -0,0 +1,345 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+type Config struct {
+	Targets []TargetsConfig `mapstructure:"targets"`
+	Search  SearchConfig    `mapstructure:"search_config"`
+	// Format is the output format of the report, e.g. markdown or json.
+	Format string        `mapstructure:"format"`
+	Report ReportConfig  `mapstructure:"report"`
+	Sinks  []SinksConfig `mapstructure:"sinks"`
+	// Publish enables sending the report to sinks.
+	Publish bool `mapstructure:"publish"`
+	// DryRun shows what would be sent to sinks without sending.
+	DryRun bool `mapstructure:"dry_run"`
+	// Timeout is a global deadline for fetching and publishing.
+	Timeout time.Duration `mapstructure:"timeout"`
+	Cache   CacheConfig   `mapstructure:"cache"`
+	// DataDir is the directory for snapshots of reports. Defaults to $XDG_DATA_HOME/taskgram
+	DataDir string `mapstructure:"data_dir"`
+	// Offline renders the report from the last snapshot without fetching targets.
+	Offline bool `mapstructure:"offline"`
+	// Args are positional command line arguments, e.g. a subcommand.
+	Args []string `mapstructure:"-"`
+}
+
+type CacheConfig struct {
+	// Dir is the directory for taskgram cache subdirectory. Defaults to $XDG_CACHE_HOME
+	Dir string `mapstructure:"dir"`
+	// Disabled turns off the cache of Notion block trees.
+	Disabled bool `mapstructure:"disabled"`
+}
+
+type ReportConfig struct {
+	// Template is a path to text/template file which replaces
+	// the built-in markdown layout of the report.
+	Template string `mapstructure:"template"`
+	// Period of the summary report: week, month or sprint. Defaults to week.
+	Period string `mapstructure:"period"`
+	// SprintLength is the sprint duration like 2w. Defaults to 2w.
+	SprintLength string `mapstructure:"sprintLength"`
+	// SprintStart is the start date of any sprint like 2022-06-06.
+	// If set, the summary covers the current sprint instead of the last SprintLength.
+	SprintStart string `mapstructure:"sprintStart"`
+}
+
+type TargetsConfig struct {
+	Name string `mapstructure:"name"`
+	Type string `mapstructure:"type"`
+	// Timeout limits the whole fetch of the target including all requests.
+	Timeout        time.Duration        `mapstructure:"timeout"`
+	Notion         NotionConfig         `mapstructure:"notion_config"`
+	GoogleCalendar GoogleCalendarConfig `mapstructure:"google_calendar_config"`
+	Jira           JiraConfig           `mapstructure:"jira_config"`
+	GitHub         GitHubConfig         `mapstructure:"github_config"`
+	Git            GitConfig            `mapstructure:"git_config"`
+	GitLab         GitLabConfig         `mapstructure:"gitlab_config"`
+	ICal           ICalConfig           `mapstructure:"ical_config"`
+	CalDAV         CalDAVConfig         `mapstructure:"caldav_config"`
+	// Options is the config of sources which are not built in taskgram,
+	// see source.DecodeOptions.
+	Options map[string]interface{} `mapstructure:"options"`
+}
+
+type SearchConfig struct {
+	LastEditedTimeStart string `mapstructure:"lastEditedTimeStart"`
+	LastEditedDateStart string `mapstructure:"lastEditedDateStart"`
+	LastEditedTimeEnd   string `mapstructure:"lastEditedTimeEnd"`
+	LastEditedDateEnd   string `mapstructure:"lastEditedDateEnd"`
+}
+
+type NotionConfig struct {
+	APIKey string `mapstructure:"apiKey"`
+	// BaseURL overrides Notion API URL, e.g. for a mock server.
+	BaseURL         string        `mapstructure:"baseURL"`
+	DatabaseID      string        `mapstructure:"databaseID"`
+	UserID          string        `maspstructure:"userID"`
+	Username        string        `mapstructure:"username"`
+	Timeout         time.Duration `mapstructure:"timeout"`
+	HeadingDoneName string        `mapstructure:"headingDoneName"`
+	HeadingToDoName string        `mapstructure:"headingToDoName"`
+	// Concurrency is the number of pages fetched in parallel. Defaults to 3.
+	Concurrency int `mapstructure:"concurrency"`
+	// RateLimit is requests per second shared by all targets with the same API key.
+	// Defaults to 3 which is the Notion API limit.
+	RateLimit float64 `mapstructure:"rateLimit"`
+	// MaxAttempts is the number of attempts of a request on 429 responses,
+	// and on 502, 503 and 504 responses to GET and HEAD requests.
+	// Defaults to 5, set 1 to disable retries.
+	MaxAttempts int `mapstructure:"maxAttempts"`
+	// StatusProperty is the name of select or status property with task status.
+	// If set, today notes are searched only in pages with non-terminal status.
+	StatusProperty string `mapstructure:"statusProperty"`
+	// TerminalStatuses are statuses of finished tasks, e.g. Done or Canceled.
+	TerminalStatuses []string `mapstructure:"terminalStatuses"`
+}
+
+type GoogleCalendarConfig struct {
+	CalendarID      string        `mapstructure:"calendarID"`
+	CredentialsPath string        `mapstructure:"credentials_path"`
+	TokenPath       string        `mapstructure:"token_path"`
+	Timeout         time.Duration `mapstructure:"timeout"`
+}
+
+type JiraConfig struct {
+	// BaseURL is the Jira site URL, e.g. https://acme.atlassian.net
+	BaseURL string `mapstructure:"baseURL"`
+	// Username is an account email for Jira Cloud basic auth.
+	// If empty, APIToken is used as a personal access token (Jira Server).
+	Username string        `mapstructure:"username"`
+	APIToken string        `mapstructure:"apiToken"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+	// JQL is an additional query to narrow down issues, e.g. "project = ACME".
+	JQL string `mapstructure:"jql"`
+	// Deployment is "cloud" or "server" for Jira Server and Data Center.
+	// Defaults to "cloud" for *.atlassian.net sites and "server" otherwise.
+	Deployment string `mapstructure:"deployment"`
+}
+
+type GitHubConfig struct {
+	// BaseURL is the REST API URL. Defaults to https://api.github.com,
+	// for GitHub Enterprise use https://HOSTNAME/api/v3
+	BaseURL string `mapstructure:"baseURL"`
+	Token   string `mapstructure:"token"`
+	// Username is your GitHub login.
+	// If not set, will try to get login from GitHub by token.
+	Username string        `mapstructure:"username"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+}
+
+type GitConfig struct {
+	// Repositories is a list of paths to local git repositories.
+	Repositories []string `mapstructure:"repositories"`
+	AuthorEmail  string   `mapstructure:"authorEmail"`
+	// MatchTasks enables attaching commits to tasks from other targets
+	// by task key in the branch name, e.g. ACME-123.
+	MatchTasks bool `mapstructure:"matchTasks"`
+	// TaskPattern is a regular expression for task keys in branch names.
+	TaskPattern string        `mapstructure:"taskPattern"`
+	Timeout     time.Duration `mapstructure:"timeout"`
+}
+
+type GitLabConfig struct {
+	// BaseURL is the GitLab instance URL. Defaults to https://gitlab.com
+	BaseURL string        `mapstructure:"baseURL"`
+	Token   string        `mapstructure:"token"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+type ICalConfig struct {
+	// Files is a list of paths to .ics files.
+	Files []string `mapstructure:"files"`
+	// URL is a calendar feed URL, e.g. secret iCal address.
+	URL     string        `mapstructure:"url"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+type CalDAVConfig struct {
+	// URL is the calendar collection URL,
+	// e.g. https://cloud.example.com/remote.php/dav/calendars/john/personal/
+	URL string `mapstructure:"url"`
+	// Username and Password are used for basic auth.
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// Token is used for bearer auth if Username is not set.
+	Token   string        `mapstructure:"token"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+type SinksConfig struct {
+	Name       string           `mapstructure:"name"`
+	Type       string           `mapstructure:"type"`
+	StatusHero StatusHeroConfig `mapstructure:"statushero_config"`
+	Telegram   TelegramConfig   `mapstructure:"telegram_config"`
+	Slack      SlackConfig      `mapstructure:"slack_config"`
+	Notion     NotionSinkConfig `mapstructure:"notion_config"`
+	Email      EmailConfig      `mapstructure:"email_config"`
+	Webhook    WebhookConfig    `mapstructure:"webhook_config"`
+	Matrix     MatrixConfig     `mapstructure:"matrix_config"`
+}
+
+type StatusHeroConfig struct {
+	// BaseURL is the API URL. Defaults to https://api.statushero.com
+	BaseURL string `mapstructure:"baseURL"`
+	APIKey  string `mapstructure:"apiKey"`
+	TeamID  string `mapstructure:"teamID"`
+	// MemberEmail is the email of the team member to check in.
+	MemberEmail string        `mapstructure:"memberEmail"`
+	Timeout     time.Duration `mapstructure:"timeout"`
+}
+
+type TelegramConfig struct {
+	// BaseURL is the Bot API URL. Defaults to https://api.telegram.org
+	BaseURL string `mapstructure:"baseURL"`
+	// Token is the bot token from @BotFather.
+	Token string `mapstructure:"token"`
+	// ChatID is a chat ID or @channelusername.
+	ChatID  string        `mapstructure:"chatID"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+type SlackConfig struct {
+	// WebhookURL is an incoming webhook URL.
+	// If set, the report is posted via webhook instead of chat.postMessage.
+	WebhookURL string `mapstructure:"webhookURL"`
+	// BaseURL is the Web API URL. Defaults to https://slack.com/api
+	BaseURL string `mapstructure:"baseURL"`
+	// Token is a bot token with chat:write scope.
+	Token   string `mapstructure:"token"`
+	Channel string `mapstructure:"channel"`
+	// ThreadMatch is a text of today's message in the channel
+	// to post the report in its thread, e.g. "Daily standup".
+	// Requires channels:history scope.
+	ThreadMatch string        `mapstructure:"threadMatch"`
+	Timeout     time.Duration `mapstructure:"timeout"`
+}
+
+type NotionSinkConfig struct {
+	APIKey string `mapstructure:"apiKey"`
+	// BaseURL overrides Notion API URL, e.g. for a mock server.
+	BaseURL string `mapstructure:"baseURL"`
+	// DatabaseID is the UUID of "Standups" database.
+	DatabaseID string `mapstructure:"databaseID"`
+	// TitleProperty is the name of the database title property. Defaults to "Name".
+	TitleProperty string `mapstructure:"titleProperty"`
+	// TitlePrefix is prepended to the report date in the page title. Defaults to "Standup".
+	TitlePrefix string `mapstructure:"titlePrefix"`
+	// DateProperty is the name of an optional date property for the report date.
+	DateProperty string        `mapstructure:"dateProperty"`
+	Timeout      time.Duration `mapstructure:"timeout"`
+}
+
+type EmailConfig struct {
+	Host string `mapstructure:"host"`
+	Port int    `mapstructure:"port"`
+	// Security is one of "starttls" (default), "tls" for implicit TLS or "none".
+	Security string   `mapstructure:"security"`
+	Username string   `mapstructure:"username"`
+	Password string   `mapstructure:"password"`
+	From     string   `mapstructure:"from"`
+	To       []string `mapstructure:"to"`
+	Cc       []string `mapstructure:"cc"`
+	// Subject is a text/template for the subject line with the report as data.
+	Subject string        `mapstructure:"subject"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+type WebhookConfig struct {
+	URL string `mapstructure:"url"`
+	// Headers are additional request headers, e.g. Authorization.
+	Headers map[string]string `mapstructure:"headers"`
+	// Secret enables HMAC-SHA256 signature of the request body.
+	Secret string `mapstructure:"secret"`
+	// SignatureHeader is the header for the signature. Defaults to X-Taskgram-Signature.
+	SignatureHeader string        `mapstructure:"signatureHeader"`
+	Timeout         time.Duration `mapstructure:"timeout"`
+}
+
+type MatrixConfig struct {
+	// HomeserverURL is the client-server API URL, e.g. https://matrix.org
+	HomeserverURL string `mapstructure:"homeserverURL"`
+	AccessToken   string `mapstructure:"accessToken"`
+	// RoomID is the room ID like !abc:example.org, the bot must be joined to the room.
+	RoomID string `mapstructure:"roomID"`
+	// MaxAttempts is the number of send attempts on 429 and 5xx responses. Defaults to 3.
+	MaxAttempts int           `mapstructure:"maxAttempts"`
+	Timeout     time.Duration `mapstructure:"timeout"`
+}
+
+func Init() (*Config, error) {
+	// Command line flags
+	pflag.StringP("starttime", "s", "24h", "Start time when notes was last updated.")
+	pflag.StringP("startdate", "d", "", "Start date when notes was last updated.")
+	pflag.StringP("endtime", "e", "", "End time when notes was last updated.")
+	pflag.StringP("enddate", "j", "", "End date when notes was last updated.")
+	pflag.StringP("format", "f", "markdown", "Output format: markdown, text, json, yaml, html or slack.")
+	pflag.BoolP("publish", "p", false, "Publish the report to configured sinks.")
+	pflag.Bool("dry-run", false, "Show what would be published to sinks without sending.")
+	pflag.Duration("timeout", 0, "Global deadline for fetching and publishing, e.g. 1m. Not limited if 0.")
+	pflag.Bool("no-cache", false, "Don't use the cache of Notion pages.")
+	pflag.Bool("offline", false, "Render the report from the last successful run without fetching.")
+	pflag.String("period", "week", "Period of the summary report: week, month or sprint.")
+	pflag.Parse()
+
+	// Bind command line flags
+	_ = viper.BindPFlag("search_config.lastEditedTimeStart", pflag.Lookup("starttime"))
+	_ = viper.BindPFlag("search_config.lastEditedDateStart", pflag.Lookup("startdate"))
+	_ = viper.BindPFlag("search_config.lastEditedTimeEnd", pflag.Lookup("endtime"))
+	_ = viper.BindPFlag("search_config.lastEditediDateEnd", pflag.Lookup("enddate"))
+	_ = viper.BindPFlag("format", pflag.Lookup("format"))
+	_ = viper.BindPFlag("publish", pflag.Lookup("publish"))
+	_ = viper.BindPFlag("dry_run", pflag.Lookup("dry-run"))
+	_ = viper.BindPFlag("timeout", pflag.Lookup("timeout"))
+	_ = viper.BindPFlag("cache.disabled", pflag.Lookup("no-cache"))
+	_ = viper.BindPFlag("offline", pflag.Lookup("offline"))
+	_ = viper.BindPFlag("report.period", pflag.Lookup("period"))
+
+	// Name of config file (without extension)
+	viper.SetConfigName(".taskgram")
+	// REQUIRED if the config file does not have the extension in the name
+	// path to look for the config file in call multiple times to add many
+	// search paths
+	viper.SetConfigType("yaml")
+	viper.AddConfigPath("configs")
+	viper.AddConfigPath("$HOME/")
+	// Find and read the config file
+	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
+			// Config file not found
+			return nil, fmt.Errorf("config file not found")
+		}
+		return nil, fmt.Errorf("failed read config file: %v\n", err)
+	}
+
+	var cfg Config
+	err := viper.Unmarshal(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Args = pflag.Args()
+
+	return &cfg, nil
+}
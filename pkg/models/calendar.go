@@ -19,10 +19,13 @@ package models
 import (
 	"bytes"
 	"fmt"
+	"time"
 )
 
 type Event struct {
-	Summary string
+	Summary string    `json:"summary" yaml:"summary"`
+	Start   time.Time `json:"start" yaml:"start"`
+	End     time.Time `json:"end" yaml:"end"`
 }
 
 type Events []Event
@@ -43,3 +46,14 @@ func (e *Events) String() string {
 func (e *Events) EventsLen() int {
 	return len(*e)
 }
+
+// EndedAfter returns events which end after t
+func (e Events) EndedAfter(t time.Time) Events {
+	var result Events
+	for _, event := range e {
+		if event.End.After(t) {
+			result = append(result, event)
+		}
+	}
+	return result
+}
@@ -0,0 +1,37 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+// Items represents tasks and events collected from a source
+type Items struct {
+	Tasks  Tasks  `json:"tasks" yaml:"tasks"`
+	Events Events `json:"events" yaml:"events"`
+	// Warnings describe tasks which were skipped because of errors.
+	Warnings []string `json:"warnings,omitempty" yaml:"warnings,omitempty"`
+}
+
+// Append adds tasks and events from other items
+func (i *Items) Append(other Items) {
+	i.Tasks = append(i.Tasks, other.Tasks...)
+	i.Events = append(i.Events, other.Events...)
+	i.Warnings = append(i.Warnings, other.Warnings...)
+}
+
+// Empty returns true if there are no notes and no events
+func (i *Items) Empty() bool {
+	return i.Tasks.NotesLen() < 1 && i.Events.EventsLen() < 1
+}
@@ -0,0 +1,50 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Report represents items collected from all targets in the search window
+type Report struct {
+	Start   time.Time `json:"start" yaml:"start"`
+	End     time.Time `json:"end" yaml:"end"`
+	Targets []string  `json:"targets" yaml:"targets"`
+	Done    Items     `json:"done" yaml:"done"`
+	Today   Items     `json:"today" yaml:"today"`
+	// SnapshotAt is the time of the snapshot if the report is rendered offline
+	SnapshotAt *time.Time `json:"snapshot_at,omitempty" yaml:"snapshot_at,omitempty"`
+}
+
+// Stale returns a notice that the report is rendered from a snapshot, empty otherwise
+func (r *Report) Stale() string {
+	if r.SnapshotAt == nil {
+		return ""
+	}
+	return fmt.Sprintf("OFFLINE: snapshot taken %s, notes after %s are missing.",
+		r.SnapshotAt.Local().Format("2006-01-02 15:04"), r.End.Local().Format("2006-01-02 15:04"))
+}
+
+// Warnings returns warnings of done and today items
+func (r *Report) Warnings() []string {
+	var warnings []string
+	warnings = append(warnings, r.Done.Warnings...)
+	warnings = append(warnings, r.Today.Warnings...)
+	return warnings
+}
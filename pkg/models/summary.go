@@ -0,0 +1,198 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Summary represents done items aggregated over a long period, e.g. a sprint
+type Summary struct {
+	Start    time.Time        `json:"start" yaml:"start"`
+	End      time.Time        `json:"end" yaml:"end"`
+	Targets  []string         `json:"targets" yaml:"targets"`
+	Projects []ProjectSummary `json:"projects" yaml:"projects"`
+	Meetings MeetingsSummary  `json:"meetings" yaml:"meetings"`
+	Warnings []string         `json:"warnings,omitempty" yaml:"warnings,omitempty"`
+}
+
+// ProjectSummary represents done tasks with the project tag.
+// Name is empty for tasks without projects.
+type ProjectSummary struct {
+	Name  string `json:"name" yaml:"name"`
+	Tasks Tasks  `json:"tasks" yaml:"tasks"`
+}
+
+// MeetingsSummary represents the time spent in meetings
+type MeetingsSummary struct {
+	Count  int            `json:"count" yaml:"count"`
+	Hours  float64        `json:"hours" yaml:"hours"`
+	Events []MeetingStats `json:"events,omitempty" yaml:"events,omitempty"`
+}
+
+// MeetingStats represents meetings with the same summary, e.g. a daily standup
+type MeetingStats struct {
+	Summary string  `json:"summary" yaml:"summary"`
+	Count   int     `json:"count" yaml:"count"`
+	Hours   float64 `json:"hours" yaml:"hours"`
+}
+
+// Summarize aggregates done items of the report.
+// Tasks are merged by URL or by title if URL is empty and repeated notes are dropped.
+// A task with several projects is listed in each of them.
+func Summarize(report *Report) *Summary {
+	s := &Summary{
+		Start:    report.Start,
+		End:      report.End,
+		Targets:  report.Targets,
+		Warnings: report.Done.Warnings,
+	}
+
+	tasks := mergeTasks(report.Done.Tasks)
+	projects := make(map[string]*ProjectSummary)
+	var names []string
+	for _, task := range tasks {
+		tags := task.Projects
+		if len(tags) < 1 {
+			tags = []string{""}
+		}
+		for _, tag := range tags {
+			key := strings.ToLower(tag)
+			p, ok := projects[key]
+			if !ok {
+				p = &ProjectSummary{Name: tag}
+				projects[key] = p
+				names = append(names, key)
+			}
+			p.Tasks = append(p.Tasks, task)
+		}
+	}
+	// Projects by name, tasks without projects go last
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) < 1 || len(names[j]) < 1 {
+			return len(names[j]) < 1 && len(names[i]) > 0
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		s.Projects = append(s.Projects, *projects[name])
+	}
+
+	s.Meetings = summarizeEvents(report.Done.Events)
+
+	return s
+}
+
+// mergeTasks merges tasks with the same URL or title keeping the order
+// of first occurrence and drops tasks without notes
+func mergeTasks(tasks Tasks) Tasks {
+	var result Tasks
+	index := make(map[string]int)
+	seen := make(map[string]map[string]bool)
+	for _, task := range tasks {
+		if !hasNotes(task.Notes) {
+			continue
+		}
+		key := task.URL
+		if len(key) < 1 {
+			key = "title:" + strings.ToLower(strings.TrimSpace(task.Title))
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(result)
+			index[key] = i
+			seen[key] = make(map[string]bool)
+			result = append(result, Task{Title: task.Title, URL: task.URL})
+		}
+		result[i].Projects = appendUnique(result[i].Projects, task.Projects...)
+		for _, note := range task.Notes {
+			normalized := strings.ToLower(strings.Join(strings.Fields(note), " "))
+			if len(normalized) < 1 || seen[key][normalized] {
+				continue
+			}
+			seen[key][normalized] = true
+			result[i].Notes = append(result[i].Notes, strings.TrimSpace(note))
+		}
+	}
+	return result
+}
+
+// hasNotes returns true if any note is not blank
+func hasNotes(notes []string) bool {
+	for _, note := range notes {
+		if len(strings.TrimSpace(note)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(list []string, items ...string) []string {
+	for _, item := range items {
+		found := false
+		for _, v := range list {
+			if strings.EqualFold(v, item) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// summarizeEvents counts meetings and their hours.
+// Events repeated by several calendars are counted once,
+// all-day and multi-day events are not meetings and are skipped.
+func summarizeEvents(events Events) MeetingsSummary {
+	var ms MeetingsSummary
+	seen := make(map[string]bool)
+	index := make(map[string]int)
+	for _, event := range events {
+		d := event.End.Sub(event.Start)
+		if d <= 0 || d >= 24*time.Hour {
+			continue
+		}
+		key := event.Summary + "\x00" + event.Start.UTC().Format(time.RFC3339)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		hours := d.Hours()
+		ms.Count++
+		ms.Hours += hours
+
+		i, ok := index[event.Summary]
+		if !ok {
+			i = len(ms.Events)
+			index[event.Summary] = i
+			ms.Events = append(ms.Events, MeetingStats{Summary: event.Summary})
+		}
+		ms.Events[i].Count++
+		ms.Events[i].Hours += hours
+	}
+	// The most time-consuming meetings first
+	sort.SliceStable(ms.Events, func(i, j int) bool {
+		return ms.Events[i].Hours > ms.Events[j].Hours
+	})
+	return ms
+}
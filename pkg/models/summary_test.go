@@ -0,0 +1,140 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	monday := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	standup := func(day int) Event {
+		start := monday.Add(time.Duration(day) * 24 * time.Hour)
+		return Event{Summary: "Standup", Start: start, End: start.Add(15 * time.Minute)}
+	}
+
+	// Done items of three daily reports appended to a summary report
+	reports := []Items{
+		{
+			Tasks: Tasks{
+				{Title: "ACME-1 Billing", URL: "https://jira.example.com/browse/ACME-1", Projects: []string{"Payments"}, Notes: []string{"Fixed rounding"}},
+				{Title: "Onboarding", Notes: []string{"Read docs"}},
+			},
+			Events:   Events{standup(0), {Summary: "Planning", Start: monday.Add(time.Hour), End: monday.Add(3 * time.Hour)}},
+			Warnings: []string{"ACME-3: forbidden"},
+		},
+		{
+			Tasks: Tasks{
+				// Repeated note differs in case and spaces
+				{Title: "ACME-1 Billing", URL: "https://jira.example.com/browse/ACME-1", Projects: []string{"payments", "Reports"}, Notes: []string{"fixed  rounding ", "Added invoices"}},
+				{Title: " onboarding", Notes: []string{"Set up laptop"}},
+				{Title: "ACME-2 Reports", URL: "https://jira.example.com/browse/ACME-2"},
+			},
+			// The same standup from two calendars and an all-day event
+			Events: Events{standup(1), standup(1), {Summary: "Vacation", Start: monday.Add(24 * time.Hour), End: monday.Add(48 * time.Hour)}},
+		},
+		{
+			Tasks:  Tasks{{Title: "ACME-1 Billing", URL: "https://jira.example.com/browse/ACME-1", Notes: []string{"Released"}}},
+			Events: Events{standup(2), standup(0)},
+		},
+	}
+
+	report := &Report{Start: monday, End: monday.Add(72 * time.Hour), Targets: []string{"jira", "gcal"}}
+	for _, items := range reports {
+		report.Done.Append(items)
+	}
+
+	billing := Task{
+		Title:    "ACME-1 Billing",
+		URL:      "https://jira.example.com/browse/ACME-1",
+		Projects: []string{"Payments", "Reports"},
+		Notes:    []string{"Fixed rounding", "Added invoices", "Released"},
+	}
+	want := &Summary{
+		Start:   monday,
+		End:     monday.Add(72 * time.Hour),
+		Targets: []string{"jira", "gcal"},
+		Projects: []ProjectSummary{
+			{Name: "Payments", Tasks: Tasks{billing}},
+			{Name: "Reports", Tasks: Tasks{billing}},
+			{Name: "", Tasks: Tasks{{Title: "Onboarding", Notes: []string{"Read docs", "Set up laptop"}}}},
+		},
+		Meetings: MeetingsSummary{
+			Count: 4,
+			Hours: 2.75,
+			Events: []MeetingStats{
+				{Summary: "Planning", Count: 1, Hours: 2},
+				{Summary: "Standup", Count: 3, Hours: 0.75},
+			},
+		},
+		Warnings: []string{"ACME-3: forbidden"},
+	}
+
+	if got := Summarize(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("Summarize() = %+v, want %+v", got, want)
+	}
+}
+
+func TestMergeTasks(t *testing.T) {
+	tests := []struct {
+		name  string
+		tasks Tasks
+		want  Tasks
+	}{
+		{
+			name: "by URL",
+			tasks: Tasks{
+				{Title: "ACME-1 Billing", URL: "https://jira.example.com/browse/ACME-1", Notes: []string{"Fixed rounding"}},
+				{Title: "ACME-1 Billing v2", URL: "https://jira.example.com/browse/ACME-1", Notes: []string{"Fixed rounding", "Released"}},
+			},
+			want: Tasks{{Title: "ACME-1 Billing", URL: "https://jira.example.com/browse/ACME-1", Notes: []string{"Fixed rounding", "Released"}}},
+		},
+		{
+			name: "same title with different URLs",
+			tasks: Tasks{
+				{Title: "Billing", URL: "https://a.example.com", Notes: []string{"Fixed rounding"}},
+				{Title: "Billing", URL: "https://b.example.com", Notes: []string{"Fixed rounding"}},
+			},
+			want: Tasks{
+				{Title: "Billing", URL: "https://a.example.com", Notes: []string{"Fixed rounding"}},
+				{Title: "Billing", URL: "https://b.example.com", Notes: []string{"Fixed rounding"}},
+			},
+		},
+		{
+			name: "by title",
+			tasks: Tasks{
+				{Title: "Onboarding", Projects: []string{"HR"}, Notes: []string{" Read docs", ""}},
+				{Title: "ONBOARDING ", Projects: []string{"hr", "IT"}, Notes: []string{"read   docs", "Set up laptop"}},
+			},
+			want: Tasks{{Title: "Onboarding", Projects: []string{"HR", "IT"}, Notes: []string{"Read docs", "Set up laptop"}}},
+		},
+		{
+			name:  "without notes",
+			tasks: Tasks{{Title: "Billing"}, {Title: "Billing", Notes: []string{" "}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeTasks(tt.tasks); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeTasks() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
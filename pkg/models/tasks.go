@@ -19,15 +19,19 @@ package models
 import (
 	"bytes"
 	"fmt"
+	"regexp"
 	"strings"
 )
 
 // Task represents a task
 type Task struct {
-	Title    string
-	URL      string
-	Projects []string
-	Notes    []string
+	Title    string   `json:"title" yaml:"title"`
+	URL      string   `json:"url,omitempty" yaml:"url,omitempty"`
+	Projects []string `json:"projects,omitempty" yaml:"projects,omitempty"`
+	Notes    []string `json:"notes" yaml:"notes"`
+	// Ref is a reference to a task from another source, e.g. issue key ACME-123.
+	// Tasks with Ref are attached to the task which title contains the Ref.
+	Ref string `json:"ref,omitempty" yaml:"ref,omitempty"`
 }
 
 // Tasks represents list of tasks
@@ -37,7 +41,11 @@ type Tasks []Task
 func (t *Task) String() string {
 	var buf bytes.Buffer
 
-	fmt.Fprintf(&buf, "- [%s](%s) ", t.Title, t.URL)
+	if len(t.URL) > 0 {
+		fmt.Fprintf(&buf, "- [%s](%s) ", t.Title, t.URL)
+	} else {
+		fmt.Fprintf(&buf, "- %s ", t.Title)
+	}
 	for _, p := range t.Projects {
 		fmt.Fprintf(&buf, "#%s ", strings.ToLower(p))
 	}
@@ -69,3 +77,35 @@ func (t *Tasks) NotesLen() (counter int) {
 	}
 	return
 }
+
+// AttachRefs moves notes of tasks with Ref to the task which title contains the Ref
+// as a whole key, e.g. ACME-12 matches "ACME-12 Fix" but not "ACME-123 Fix".
+// Tasks with Ref which don't match any title are kept as is.
+func (t Tasks) AttachRefs() Tasks {
+	var result Tasks
+	var refs Tasks
+	for _, task := range t {
+		if len(task.Ref) > 0 {
+			refs = append(refs, task)
+		} else {
+			result = append(result, task)
+		}
+	}
+
+	for _, ref := range refs {
+		key := regexp.MustCompile(`(^|[^A-Za-z0-9])` + regexp.QuoteMeta(ref.Ref) + `([^0-9]|$)`)
+		attached := false
+		for i := range result {
+			if len(result[i].Ref) < 1 && key.MatchString(result[i].Title) {
+				result[i].Notes = append(result[i].Notes, ref.Notes...)
+				attached = true
+				break
+			}
+		}
+		if !attached {
+			result = append(result, ref)
+		}
+	}
+
+	return result
+}
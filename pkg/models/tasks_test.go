@@ -0,0 +1,42 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAttachRefs(t *testing.T) {
+	tasks := Tasks{
+		{Title: "ACME-123 Billing migration"},
+		{Title: "[ACME-12] Login page"},
+		{Title: "module (ACME-12-login)", Ref: "ACME-12", Notes: []string{"Fix login form"}},
+		{Title: "module (ACME-123)", Ref: "ACME-123", Notes: []string{"Migrate invoices"}},
+		{Title: "module (ACME-1-typo)", Ref: "ACME-1", Notes: []string{"Fix typo"}},
+	}
+
+	got := tasks.AttachRefs()
+	want := Tasks{
+		{Title: "ACME-123 Billing migration", Notes: []string{"Migrate invoices"}},
+		{Title: "[ACME-12] Login page", Notes: []string{"Fix login form"}},
+		{Title: "module (ACME-1-typo)", Ref: "ACME-1", Notes: []string{"Fix typo"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AttachRefs() = %+v, want %+v", got, want)
+	}
+}
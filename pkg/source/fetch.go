@@ -0,0 +1,80 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/nemca/taskgram/internal/helpers"
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+// Result is the result of fetching a target.
+type Result struct {
+	Target string
+	Done   models.Items
+	Today  models.Items
+	Err    error
+}
+
+// FetchAll fetches all targets concurrently and returns results in the order of targets.
+// A failed target doesn't stop others, its error is returned in the result.
+func FetchAll(ctx context.Context, targets []config.TargetsConfig, sc *models.SearchConfig) []Result {
+	results := make([]Result, len(targets))
+
+	wg := new(sync.WaitGroup)
+	for i := range targets {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = fetch(ctx, &targets[i], sc)
+		}(i)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// fetch creates a repository for the target and fetches items within the target timeout.
+// It returns as soon as the context is done even if the repository ignores it.
+func fetch(ctx context.Context, target *config.TargetsConfig, sc *models.SearchConfig) Result {
+	ctx, cancel := helpers.WithTimeout(ctx, target.Timeout)
+	defer cancel()
+
+	resultCh := make(chan Result, 1)
+	go func() {
+		result := Result{Target: target.Name}
+		repo, err := New(target)
+		if err != nil {
+			result.Err = fmt.Errorf("create repository: %v", err)
+			resultCh <- result
+			return
+		}
+		result.Done, result.Today, result.Err = repo.Fetch(ctx, sc)
+		resultCh <- result
+	}()
+
+	select {
+	case result := <-resultCh:
+		return result
+	case <-ctx.Done():
+		return Result{Target: target.Name, Err: ctx.Err()}
+	}
+}
@@ -0,0 +1,84 @@
+/*
+Copyright © 2022 Michael Bruskov <mixanemca@yandex.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package source is the contract of task and event sources.
+// A source registers its factory by the target type in init:
+//
+//	func init() {
+//		source.Register("acme", func(target *config.TargetsConfig) (source.Repository, error) {
+//			var opts acmeOptions
+//			if err := source.DecodeOptions(target, &opts); err != nil {
+//				return nil, err
+//			}
+//			return &acmeRepository{opts: opts}, nil
+//		})
+//	}
+//
+// and is built in taskgram by a blank import next to cli.Main, see README.
+package source
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/nemca/taskgram/internal/registry"
+	"github.com/nemca/taskgram/pkg/config"
+	"github.com/nemca/taskgram/pkg/models"
+)
+
+// Repository is a source of tasks and events.
+type Repository interface {
+	// Fetch returns items done in the search window and items planned for today.
+	Fetch(ctx context.Context, sc *models.SearchConfig) (done models.Items, today models.Items, err error)
+}
+
+// Factory creates a Repository from the target config.
+type Factory func(target *config.TargetsConfig) (Repository, error)
+
+var factories = registry.New[Factory]("target")
+
+// Register makes a source factory available by the target type.
+// If Register is called twice with the same type or if factory is nil, it panics.
+func Register(targetType string, factory Factory) {
+	if factory == nil {
+		panic("source: Register factory is nil")
+	}
+	factories.Register(targetType, factory)
+}
+
+// New creates a repository for the target by its type.
+func New(target *config.TargetsConfig) (Repository, error) {
+	factory, err := factories.Get(target.Type)
+	if err != nil {
+		return nil, err
+	}
+	return factory(target)
+}
+
+// Types returns a sorted list of the registered target types.
+func Types() []string {
+	return factories.Types()
+}
+
+// DecodeOptions decodes options section of the target config to v,
+// it's the config of sources which are not built in taskgram.
+func DecodeOptions(target *config.TargetsConfig, v interface{}) error {
+	if err := mapstructure.WeakDecode(target.Options, v); err != nil {
+		return fmt.Errorf("decode %s options: %v", target.Name, err)
+	}
+	return nil
+}